@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/turbo-export-engine/internal/job"
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// newExportCmd builds the csv and xlsx commands, which differ only in format
+func newExportCmd(format types.ExportFormat, short string) *cobra.Command {
+	flags := &commonFlags{}
+	xlsxFlags := &xlsxFlags{}
+	csvFlags := &csvFlags{}
+
+	cmd := &cobra.Command{
+		Use:   string(format),
+		Short: short,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(cmd, format, flags, xlsxFlags, csvFlags)
+		},
+	}
+	flags.register(cmd)
+	if format == types.FormatXLSX {
+		xlsxFlags.register(cmd)
+	} else {
+		csvFlags.register(cmd)
+	}
+
+	return cmd
+}
+
+func runExport(cmd *cobra.Command, format types.ExportFormat, flags *commonFlags, xlsxFlags *xlsxFlags, csvFlags *csvFlags) error {
+	mode, err := flags.validate()
+	if err != nil {
+		return err
+	}
+	xlsxOpts, err := xlsxFlags.options()
+	if err != nil {
+		return err
+	}
+	csvOpts, err := csvFlags.options()
+	if err != nil {
+		return err
+	}
+	protect, err := flags.injectionOptions()
+	if err != nil {
+		return err
+	}
+	missingOpts, err := flags.missingOptions()
+	if err != nil {
+		return err
+	}
+	numberOpts, err := flags.numberOptions()
+	if err != nil {
+		return err
+	}
+
+	in, err := openInput(flags)
+	if err != nil {
+		return inputError(err)
+	}
+	defer in.Close()
+	if format != types.FormatXLSX {
+		if err := in.requireSingleSheet(string(format)); err != nil {
+			return err
+		}
+	}
+
+	exportJob := &types.ExportJob{
+		ID: fmt.Sprintf("%s-%d", format, time.Now().UnixNano()),
+		Config: &types.ExportConfig{
+			Mode:       mode,
+			Format:     format,
+			Workers:    flags.workers,
+			ChunkSize:  flags.chunkSize,
+			InputPath:  flags.input,
+			OutputPath: flags.output,
+			Output:     flags.destination(),
+			XLSX:       xlsxOpts,
+			CSV:        csvOpts,
+			Injection:  protect,
+			Missing:    missingOpts,
+			Numbers:    numberOpts,
+		},
+		Headers: in.headers,
+		Source:  in.source,
+	}
+	if in.sheets != nil {
+		exportJob.Sheets = in.sheets
+	}
+
+	ctx, cancel := flags.context(cmd)
+	defer cancel()
+
+	start := time.Now()
+	err = executeJob(ctx, exportJob)
+	if decodeErr := in.err(); decodeErr != nil {
+		return inputError(decodeErr)
+	}
+	if err != nil {
+		return exportError(fmt.Errorf("%s export failed: %w", format, err))
+	}
+
+	out := flags.reportTo(cmd)
+	fmt.Fprintf(out, "Export completed\n")
+	fmt.Fprintf(out, "Output: %s\n", flags.output)
+	fmt.Fprintf(out, "Total Rows: %d\n", in.count())
+	if exportJob.LossyCells > 0 {
+		fmt.Fprintf(out, "Lossy Cells: %d\n", exportJob.LossyCells)
+	}
+	fmt.Fprintf(out, "Duration: %s\n", time.Since(start).Round(time.Millisecond))
+	if len(exportJob.SheetResults) > 0 {
+		fmt.Fprintf(out, "Sheets: %d\n", len(exportJob.SheetResults))
+		for _, sheet := range exportJob.SheetResults {
+			fmt.Fprintf(out, "  - %s: %d rows\n", sheet.Name, sheet.Rows)
+		}
+	}
+
+	return nil
+}
+
+// executeJob dispatches the job to the executor matching its mode
+func executeJob(ctx context.Context, exportJob *types.ExportJob) error {
+	switch exportJob.Config.Mode {
+	case types.ModeParallel:
+		return job.NewParallelExecutor().ExecuteContext(ctx, exportJob)
+	case types.ModeGlobalPool:
+		executor := job.NewPoolExecutor(exportJob.Config.Workers)
+		defer executor.Shutdown()
+		return executor.ExecuteContext(ctx, exportJob)
+	default:
+		return job.NewSyncExecutor().ExecuteContext(ctx, exportJob)
+	}
+}
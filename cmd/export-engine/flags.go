@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+
+	"github.com/turbo-export-engine/internal/csv"
+	"github.com/turbo-export-engine/internal/injection"
+	"github.com/turbo-export-engine/internal/input"
+	"github.com/turbo-export-engine/internal/missing"
+	"github.com/turbo-export-engine/internal/values"
+	"github.com/turbo-export-engine/internal/xlsx"
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// commonFlags holds the flags shared by every export command
+type commonFlags struct {
+	input       string
+	inputFormat string
+	headers     []string
+	columns     []string
+	inferWindow int
+	output      string
+	mode        string
+	workers     int
+	chunkSize   int
+	timeout     time.Duration
+
+	injection        string
+	injectionColumns map[string]string
+	null             string
+	ragged           string
+	floatPrecision   int
+}
+
+func (f *commonFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.input, "input", "", "Input JSON file, - for stdin, or an inline JSON document (required)")
+	cmd.Flags().StringVar(&f.inputFormat, "input-format", inputJSON, "Input format: json or ndjson")
+	cmd.Flags().StringSliceVar(&f.headers, "headers", nil, "Header row for ndjson input; when set, the first line is a data row")
+	cmd.Flags().StringSliceVar(&f.columns, "columns", nil, "Columns to export, in order, selected by header name or object key")
+	cmd.Flags().IntVar(&f.inferWindow, "infer-window", input.DefaultInferWindow, "Number of leading object rows sampled to infer headers")
+	cmd.Flags().StringVar(&f.output, "output", "", "Output file path, or - for stdout (required)")
+	cmd.Flags().StringVar(&f.mode, "mode", string(types.ModeSync), "Execution mode: sync, parallel or global_pool")
+	cmd.Flags().IntVar(&f.workers, "workers", 4, "Number of workers")
+	cmd.Flags().IntVar(&f.chunkSize, "chunk-size", 10000, "Rows per chunk")
+	cmd.Flags().DurationVar(&f.timeout, "timeout", 0, "Abort the export after this duration, e.g. 30s (0 disables)")
+	cmd.Flags().StringVar(&f.injection, "injection", string(types.InjectionOff), "Formula injection protection of text cells: off, prefix (with '), strip or reject")
+	cmd.Flags().StringToStringVar(&f.injectionColumns, "injection-columns", nil, "Injection protection by header, overriding --injection, e.g. Comment=prefix,Formula=off")
+	cmd.Flags().StringVar(&f.null, "null", "", `Text written for null and missing values, e.g. NULL or \N (default empty)`)
+	cmd.Flags().StringVar(&f.ragged, "ragged", string(types.RaggedPad), "Rows whose length differs from the headers: pad, truncate or error")
+	cmd.Flags().IntVar(&f.floatPrecision, "float-precision", -1, "Decimals of fractional numbers written as text, 0 rounding them to integers (-1 keeps them exact)")
+}
+
+// validate checks the shared flags and returns the parsed execution mode
+func (f *commonFlags) validate() (types.ExportMode, error) {
+	if f.input == "" {
+		return "", usageErrorf("--input is required")
+	}
+	if f.output == "" {
+		return "", usageErrorf("--output is required")
+	}
+	if f.workers <= 0 {
+		return "", usageErrorf("--workers must be positive, got %d", f.workers)
+	}
+	if f.chunkSize <= 0 {
+		return "", usageErrorf("--chunk-size must be positive, got %d", f.chunkSize)
+	}
+	if f.timeout < 0 {
+		return "", usageErrorf("--timeout must not be negative, got %s", f.timeout)
+	}
+	if f.inferWindow <= 0 {
+		return "", usageErrorf("--infer-window must be positive, got %d", f.inferWindow)
+	}
+	switch f.inputFormat {
+	case inputJSON:
+		if len(f.headers) > 0 {
+			return "", usageErrorf("--headers is only supported with --input-format=%s", inputNDJSON)
+		}
+	case inputNDJSON:
+	default:
+		return "", usageErrorf("invalid --input-format %q: expected %s or %s", f.inputFormat, inputJSON, inputNDJSON)
+	}
+	return parseMode(f.mode)
+}
+
+// injectionOptions converts the injection flags into injection options
+func (f *commonFlags) injectionOptions() (types.InjectionOptions, error) {
+	opts := types.InjectionOptions{Policy: types.InjectionPolicy(f.injection)}
+	if len(f.injectionColumns) > 0 {
+		opts.Columns = make(map[string]types.InjectionPolicy, len(f.injectionColumns))
+		for name, value := range f.injectionColumns {
+			opts.Columns[name] = types.InjectionPolicy(value)
+		}
+	}
+
+	if err := injection.CheckOptions(opts); err != nil {
+		return opts, usageErrorf("%v", err)
+	}
+	return opts, nil
+}
+
+// missingOptions converts the null and ragged row flags into options
+func (f *commonFlags) missingOptions() (types.MissingOptions, error) {
+	opts := types.MissingOptions{Null: f.null, Ragged: types.RaggedPolicy(f.ragged)}
+	if err := missing.CheckOptions(opts); err != nil {
+		return opts, usageErrorf("%v", err)
+	}
+	return opts, nil
+}
+
+// numberOptions converts the number formatting flags into options; a float
+// precision of -1 keeps floats exact
+func (f *commonFlags) numberOptions() (types.NumberOptions, error) {
+	var opts types.NumberOptions
+	if f.floatPrecision != -1 {
+		precision := f.floatPrecision
+		opts.FloatPrecision = &precision
+	}
+	if err := values.CheckOptions(opts); err != nil {
+		return opts, usageErrorf("%v", err)
+	}
+	return opts, nil
+}
+
+func parseMode(value string) (types.ExportMode, error) {
+	switch mode := types.ExportMode(value); mode {
+	case types.ModeSync, types.ModeParallel, types.ModeGlobalPool:
+		return mode, nil
+	default:
+		return "", usageErrorf("invalid --mode %q: expected sync, parallel or global_pool", value)
+	}
+}
+
+func parseFormat(value string) (types.ExportFormat, error) {
+	switch format := types.ExportFormat(value); format {
+	case types.FormatCSV, types.FormatXLSX:
+		return format, nil
+	default:
+		return "", usageErrorf("invalid --format %q: expected csv or xlsx", value)
+	}
+}
+
+// destination returns the writer replacing the output file, or nil when the
+// export goes to --output
+func (f *commonFlags) destination() io.Writer {
+	if f.output == stdio {
+		return os.Stdout
+	}
+	return nil
+}
+
+// reportTo returns where the export summary is printed. It moves to stderr
+// when stdout carries the exported bytes.
+func (f *commonFlags) reportTo(cmd *cobra.Command) io.Writer {
+	if f.output == stdio {
+		return cmd.ErrOrStderr()
+	}
+	return cmd.OutOrStdout()
+}
+
+// context returns the command context bounded by --timeout
+func (f *commonFlags) context(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	if f.timeout > 0 {
+		return context.WithTimeout(cmd.Context(), f.timeout)
+	}
+	return context.WithCancel(cmd.Context())
+}
+
+// xlsxFlags holds the flags shaping XLSX output, shared by the xlsx and
+// split-zip commands
+type xlsxFlags struct {
+	columnTypes        map[string]string
+	detectDates        bool
+	sharedStrings      bool
+	sharedStringsLimit int
+	boldHeaders        bool
+	headerFill         string
+	numberFormats      map[string]string
+	columnWidths       map[string]string
+	autoWidth          bool
+	maxSheetRows       int
+	repeatHeaders      bool
+	freezeHeader       bool
+	autoFilter         bool
+	table              bool
+	tableName          string
+	tableStyle         string
+	controlChars       string
+	longText           string
+	title              string
+	subject            string
+	creator            string
+	company            string
+	keywords           string
+	created            string
+	bufferSheets       bool
+}
+
+func (f *xlsxFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringToStringVar(&f.columnTypes, "column-types", nil, "XLSX cell types by header, e.g. Age=number,Joined=date (string, number, bool, date, datetime, hyperlink, formula)")
+	cmd.Flags().BoolVar(&f.detectDates, "detect-dates", true, "Write RFC 3339 and ISO 8601 date strings as XLSX dates")
+	cmd.Flags().BoolVar(&f.sharedStrings, "shared-strings", false, "Deduplicate XLSX text cells in a shared strings table")
+	cmd.Flags().IntVar(&f.sharedStringsLimit, "shared-strings-limit", 64, "Memory cap of the shared strings table in MiB; further strings are written inline")
+	cmd.Flags().BoolVar(&f.boldHeaders, "bold-headers", false, "Write the XLSX header row in bold")
+	cmd.Flags().StringVar(&f.headerFill, "header-fill", "", "Fill the XLSX header row with an RGB hex color, e.g. DDEBF7")
+	cmd.Flags().StringToStringVar(&f.numberFormats, "number-formats", nil, "XLSX number formats by header, e.g. Salary=currency (currency, percent, date, datetime, thousands, decimal or a format code)")
+	cmd.Flags().StringToStringVar(&f.columnWidths, "column-widths", nil, "XLSX column widths in characters by header, e.g. Name=30")
+	cmd.Flags().BoolVar(&f.autoWidth, "auto-width", false, "Estimate XLSX column widths from the first rows")
+	cmd.Flags().IntVar(&f.maxSheetRows, "max-sheet-rows", 0, "Roll XLSX rows over into a new sheet after this many rows, header included (0 uses Excel's limit of 1048576)")
+	cmd.Flags().BoolVar(&f.repeatHeaders, "repeat-headers", true, "Repeat the header row on XLSX rollover sheets")
+	cmd.Flags().BoolVar(&f.freezeHeader, "freeze-header", false, "Freeze the XLSX header row")
+	cmd.Flags().BoolVar(&f.autoFilter, "autofilter", false, "Add filter buttons to the XLSX header row")
+	cmd.Flags().BoolVar(&f.table, "table", false, "Format each XLSX sheet as an Excel table")
+	cmd.Flags().StringVar(&f.tableName, "table-name", "", "Name of the XLSX table (default Table1, Table2, ...)")
+	cmd.Flags().StringVar(&f.tableStyle, "table-style", "", "Built-in XLSX table style (default TableStyleMedium2)")
+	cmd.Flags().StringVar(&f.controlChars, "control-chars", "strip", "XLSX handling of characters XML cannot hold, such as \\x00 (strip, replace or encode)")
+	cmd.Flags().StringVar(&f.longText, "long-text", "truncate", "XLSX handling of text over Excel's 32767 character cell limit (truncate or error)")
+	cmd.Flags().BoolVar(&f.bufferSheets, "buffer-sheets", true, "Buffer streamed XLSX sheets in a temporary file to write their dimension ahead of the rows")
+	cmd.Flags().StringVar(&f.title, "title", "", "XLSX document title")
+	cmd.Flags().StringVar(&f.subject, "subject", "", "XLSX document subject")
+	cmd.Flags().StringVar(&f.creator, "creator", "", "XLSX document author")
+	cmd.Flags().StringVar(&f.company, "company", "", "XLSX document company")
+	cmd.Flags().StringVar(&f.keywords, "keywords", "", "XLSX document keywords")
+	cmd.Flags().StringVar(&f.created, "created", "", "XLSX creation timestamp: RFC 3339, a YYYY-MM-DD date or \"now\" (default omitted)")
+}
+
+// options converts the flags into XLSX options
+func (f *xlsxFlags) options() (types.XLSXOptions, error) {
+	if f.sharedStrings && f.sharedStringsLimit <= 0 {
+		return types.XLSXOptions{}, usageErrorf("--shared-strings-limit must be positive, got %d", f.sharedStringsLimit)
+	}
+
+	opts := types.XLSXOptions{
+		DetectDates:        f.detectDates,
+		SharedStrings:      f.sharedStrings,
+		SharedStringsLimit: int64(f.sharedStringsLimit) << 20,
+		BoldHeaders:        f.boldHeaders,
+		HeaderFill:         f.headerFill,
+		NumberFormats:      f.numberFormats,
+		AutoWidth:          f.autoWidth,
+		MaxRowsPerSheet:    f.maxSheetRows,
+		RepeatHeaders:      f.repeatHeaders,
+		FreezeHeader:       f.freezeHeader,
+		AutoFilter:         f.autoFilter,
+		Table:              f.table,
+		TableName:          f.tableName,
+		TableStyle:         f.tableStyle,
+		ControlChars:       types.ControlCharPolicy(f.controlChars),
+		LongText:           types.LongTextPolicy(f.longText),
+		BufferSheets:       f.bufferSheets,
+		Properties: types.DocumentProperties{
+			Title:    f.title,
+			Subject:  f.subject,
+			Creator:  f.creator,
+			Company:  f.company,
+			Keywords: f.keywords,
+		},
+	}
+
+	switch f.created {
+	case "":
+	case "now":
+		opts.Properties.Created = time.Now()
+	default:
+		created, err := time.Parse(time.RFC3339, f.created)
+		if err != nil {
+			created, err = time.Parse("2006-01-02", f.created)
+		}
+		if err != nil {
+			return opts, usageErrorf("invalid --created %q: expected an RFC 3339 timestamp, a YYYY-MM-DD date or now", f.created)
+		}
+		opts.Properties.Created = created
+	}
+
+	switch opts.ControlChars {
+	case "", types.ControlCharsStrip, types.ControlCharsReplace, types.ControlCharsEncode:
+	default:
+		return opts, usageErrorf("invalid --control-chars %q: expected strip, replace or encode", f.controlChars)
+	}
+	switch opts.LongText {
+	case "", types.LongTextTruncate, types.LongTextError:
+	default:
+		return opts, usageErrorf("invalid --long-text %q: expected truncate or error", f.longText)
+	}
+
+	if f.maxSheetRows < 0 || f.maxSheetRows == 1 || f.maxSheetRows > xlsx.MaxSheetRows {
+		return opts, usageErrorf("--max-sheet-rows must be 0 or between 2 and %d, got %d", xlsx.MaxSheetRows, f.maxSheetRows)
+	}
+
+	if f.headerFill != "" && !isRGB(strings.TrimPrefix(f.headerFill, "#")) {
+		return opts, usageErrorf("invalid --header-fill %q: expected an RGB hex color such as DDEBF7", f.headerFill)
+	}
+
+	if len(f.columnWidths) > 0 {
+		opts.ColumnWidths = make(map[string]float64, len(f.columnWidths))
+		for name, value := range f.columnWidths {
+			width, err := strconv.ParseFloat(value, 64)
+			if err != nil || width <= 0 || width > 255 {
+				return opts, usageErrorf("invalid --column-widths width %q for %q: expected a number from 0 to 255", value, name)
+			}
+			opts.ColumnWidths[name] = width
+		}
+	}
+
+	if len(f.columnTypes) > 0 {
+		opts.ColumnTypes = make(map[string]types.CellType, len(f.columnTypes))
+		for name, value := range f.columnTypes {
+			switch cellType := types.CellType(value); cellType {
+			case types.CellString, types.CellNumber, types.CellBool, types.CellDate, types.CellDateTime,
+				types.CellHyperlink, types.CellFormula:
+				opts.ColumnTypes[name] = cellType
+			default:
+				return opts, usageErrorf("invalid --column-types type %q for %q: expected string, number, bool, date, datetime, hyperlink or formula", value, name)
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// isRGB reports whether s is a six digit hex color
+func isRGB(s string) bool {
+	if len(s) != 6 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// csvFlags holds the flags setting the CSV dialect, shared by the csv and
+// split-zip commands
+type csvFlags struct {
+	delimiter   string
+	quoting     string
+	escape      string
+	crlf        bool
+	bom         bool
+	charset     string
+	unencodable string
+}
+
+func (f *csvFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.delimiter, "delimiter", ",", `CSV field delimiter, e.g. ";" or "tab" for TSV`)
+	cmd.Flags().StringVar(&f.quoting, "quoting", string(types.QuoteMinimal), "CSV quoting: minimal (only fields that need it) or all")
+	cmd.Flags().StringVar(&f.escape, "escape", string(types.EscapeDouble), `CSV escaping of quotes in quoted fields: double ("") or backslash (\")`)
+	cmd.Flags().BoolVar(&f.crlf, "crlf", false, "End CSV records with CRLF instead of LF")
+	cmd.Flags().BoolVar(&f.bom, "bom", false, "Start CSV output with a UTF-8 byte order mark, as Excel expects")
+	cmd.Flags().StringVar(&f.charset, "charset", types.CharsetUTF8, "CSV output charset: utf-8, windows-1252 or shift-jis")
+	cmd.Flags().StringVar(&f.unencodable, "unencodable", string(types.UnencodableError), "Characters the charset cannot represent: error, replace (with ?) or transliterate")
+}
+
+// options converts the flags into CSV options. Flags of commands that do
+// not register them are empty and select the defaults.
+func (f *csvFlags) options() (types.CSVOptions, error) {
+	opts := types.CSVOptions{
+		Quoting:     types.CSVQuoting(f.quoting),
+		Escape:      types.CSVEscape(f.escape),
+		CRLF:        f.crlf,
+		BOM:         f.bom,
+		Charset:     f.charset,
+		Unencodable: types.UnencodablePolicy(f.unencodable),
+	}
+
+	switch f.delimiter {
+	case "":
+	case "tab", `\t`:
+		opts.Delimiter = '\t'
+	default:
+		delimiter, size := utf8.DecodeRuneInString(f.delimiter)
+		if size != len(f.delimiter) {
+			return opts, usageErrorf("invalid --delimiter %q: expected a single character or tab", f.delimiter)
+		}
+		opts.Delimiter = delimiter
+	}
+
+	if err := csv.CheckOptions(opts); err != nil {
+		return opts, usageErrorf("%v", err)
+	}
+	return opts, nil
+}
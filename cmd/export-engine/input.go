@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/turbo-export-engine/internal/input"
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// Supported values of --input-format
+const (
+	inputJSON   = "json"
+	inputNDJSON = "ndjson"
+)
+
+// rowDecoder is implemented by every input decoder
+type rowDecoder interface {
+	types.RowDecoder
+	Headers() ([]string, error)
+	Count() int
+}
+
+// inputStream decodes the export input incrementally
+type inputStream struct {
+	name    string
+	reader  io.Reader
+	decoder rowDecoder
+	headers []string
+	source  types.RowSource
+	sheets  *input.SheetDecoder // set for multi-sheet documents
+}
+
+// stdio is the --input and --output value selecting stdin and stdout
+const stdio = "-"
+
+// openInput opens the input described by the flags and reads it up to its
+// first row. An --input value of "-" reads stdin, and one starting with '{'
+// or '[' is decoded inline instead of being treated as a path.
+func openInput(flags *commonFlags) (*inputStream, error) {
+	in := &inputStream{name: flags.input}
+
+	if inline := strings.TrimSpace(flags.input); strings.HasPrefix(inline, "{") || strings.HasPrefix(inline, "[") {
+		in.name = "inline input"
+		in.reader = strings.NewReader(inline)
+	} else if flags.input == stdio {
+		// Hide Close so stdin stays open
+		in.name = "stdin"
+		in.reader = struct{ io.Reader }{os.Stdin}
+	} else {
+		file, err := os.Open(flags.input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file: %w", err)
+		}
+		in.reader = file
+	}
+
+	opts := input.Options{
+		Headers:     flags.headers,
+		Columns:     flags.columns,
+		InferWindow: flags.inferWindow,
+	}
+	var dec *input.Decoder
+	switch flags.inputFormat {
+	case inputNDJSON:
+		in.decoder = input.NewNDJSONDecoder(in.reader, opts)
+	default:
+		dec = input.NewDecoder(in.reader, opts)
+		in.decoder = dec
+	}
+
+	headers, err := in.decoder.Headers()
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("failed to parse %s: %w", in.name, err)
+	}
+
+	if dec != nil && dec.Sheets() {
+		in.sheets = input.NewSheetDecoder(dec)
+		return in, nil
+	}
+
+	in.headers = headers
+	in.source = types.NewDecoderSource(in.decoder)
+	return in, nil
+}
+
+// err reports a decoding failure hit while the rows were being consumed
+func (in *inputStream) err() error {
+	var err error
+	if in.sheets != nil {
+		err = in.sheets.Err()
+	} else {
+		err = in.source.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", in.name, err)
+	}
+	return nil
+}
+
+// count returns the number of rows decoded so far
+func (in *inputStream) count() int {
+	if in.sheets != nil {
+		return in.sheets.Count()
+	}
+	return in.decoder.Count()
+}
+
+// requireSingleSheet rejects multi-sheet documents for commands that write
+// a single table
+func (in *inputStream) requireSingleSheet(command string) error {
+	if in.sheets != nil {
+		return usageErrorf("multi-sheet input documents are only supported by the xlsx command, not %s", command)
+	}
+	return nil
+}
+
+func (in *inputStream) Close() error {
+	if closer, ok := in.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// Exit codes returned by the export-engine binary
+const (
+	exitOK      = 0
+	exitFailure = 1 // export failed while writing output
+	exitUsage   = 2 // invalid flags or arguments
+	exitInput   = 3 // input could not be read or parsed
+	exitAborted = 4 // export cancelled or timed out
+)
+
+// exitError carries the process exit code for an error returned by a command
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+func inputError(err error) error {
+	return &exitError{code: exitInput, err: err}
+}
+
+// exportError wraps a failed export, distinguishing exports that were
+// interrupted or ran past --timeout
+func exportError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return &exitError{code: exitAborted, err: err}
+	}
+	return &exitError{code: exitFailure, err: err}
+}
+
+// newRootCmd builds the export-engine command tree
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:           "export-engine",
+		Short:         "High-performance CSV and XLSX export engine",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &exitError{code: exitUsage, err: err}
+	})
+
+	root.AddCommand(
+		newExportCmd(types.FormatCSV, "Export rows to a CSV file"),
+		newExportCmd(types.FormatXLSX, "Export rows to an XLSX file"),
+		newSplitZipCmd(),
+	)
+
+	return root
+}
+
+// execute runs the root command and maps the result to an exit code.
+// SIGINT and SIGTERM cancel the running export.
+func execute() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCmd().ExecuteContext(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+
+		var panicErr *types.PanicError
+		if errors.As(err, &panicErr) {
+			fmt.Fprintf(os.Stderr, "%s", panicErr.Stack)
+		}
+
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			return exitErr.code
+		}
+		// Errors not produced by our commands come from cobra itself
+		// (unknown command, missing arguments) and are usage errors
+		return exitUsage
+	}
+	return exitOK
+}
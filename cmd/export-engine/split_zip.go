@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/turbo-export-engine/internal/splitzip"
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// splitZipFlags holds the flags specific to the split-zip command
+type splitZipFlags struct {
+	commonFlags
+	xlsx           xlsxFlags
+	csv            csvFlags
+	format         string
+	includeHeaders bool
+	split          bool
+	zip            bool
+}
+
+func newSplitZipCmd() *cobra.Command {
+	flags := &splitZipFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "split-zip",
+		Short: "Split rows into multiple files and bundle them in a ZIP archive",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSplitZip(cmd, flags)
+		},
+	}
+	flags.register(cmd)
+	flags.xlsx.register(cmd)
+	flags.csv.register(cmd)
+	cmd.Flags().StringVar(&flags.format, "format", string(types.FormatCSV), "Part file format: csv or xlsx")
+	cmd.Flags().BoolVar(&flags.includeHeaders, "include-headers", true, "Write the header row in every part")
+	cmd.Flags().BoolVar(&flags.split, "split", true, "Split rows into parts of --chunk-size rows")
+	cmd.Flags().BoolVar(&flags.zip, "zip", true, "Bundle the parts into a ZIP archive")
+
+	return cmd
+}
+
+func runSplitZip(cmd *cobra.Command, flags *splitZipFlags) error {
+	mode, err := flags.validate()
+	if err != nil {
+		return err
+	}
+	format, err := parseFormat(flags.format)
+	if err != nil {
+		return err
+	}
+	xlsxOpts, err := flags.xlsx.options()
+	if err != nil {
+		return err
+	}
+	csvOpts, err := flags.csv.options()
+	if err != nil {
+		return err
+	}
+	protect, err := flags.injectionOptions()
+	if err != nil {
+		return err
+	}
+	missingOpts, err := flags.missingOptions()
+	if err != nil {
+		return err
+	}
+	numberOpts, err := flags.numberOptions()
+	if err != nil {
+		return err
+	}
+	if !flags.split || !flags.zip {
+		return usageErrorf("--split and --zip must both be enabled")
+	}
+
+	in, err := openInput(&flags.commonFlags)
+	if err != nil {
+		return inputError(err)
+	}
+	defer in.Close()
+	if err := in.requireSingleSheet("split-zip"); err != nil {
+		return err
+	}
+
+	splitter := splitzip.NewSplitter(&types.SplitZipConfig{
+		Split:          flags.split,
+		Zip:            flags.zip,
+		ChunkSize:      flags.chunkSize,
+		Format:         format,
+		Mode:           mode,
+		Workers:        flags.workers,
+		IncludeHeaders: flags.includeHeaders,
+		OutputPath:     flags.output,
+		Output:         flags.destination(),
+		XLSX:           xlsxOpts,
+		CSV:            csvOpts,
+		Injection:      protect,
+		Missing:        missingOpts,
+		Numbers:        numberOpts,
+	})
+
+	ctx, cancel := flags.context(cmd)
+	defer cancel()
+
+	start := time.Now()
+	result, err := splitter.ExecuteContext(ctx, in.headers, in.source)
+	if decodeErr := in.err(); decodeErr != nil {
+		return inputError(decodeErr)
+	}
+	if err != nil {
+		return exportError(fmt.Errorf("split-zip export failed: %w", err))
+	}
+
+	out := flags.reportTo(cmd)
+	fmt.Fprintf(out, "Split + ZIP completed\n")
+	fmt.Fprintf(out, "Output: %s\n", result.OutputPath)
+	fmt.Fprintf(out, "Total Parts: %d\n", result.TotalParts)
+	fmt.Fprintf(out, "Total Rows: %d\n", result.TotalRows)
+	if result.LossyCells > 0 {
+		fmt.Fprintf(out, "Lossy Cells: %d\n", result.LossyCells)
+	}
+	fmt.Fprintf(out, "Duration: %s\n", time.Since(start).Round(time.Millisecond))
+	fmt.Fprintf(out, "Part Files:\n")
+	for _, name := range result.PartFiles {
+		fmt.Fprintf(out, "  - %s\n", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package chunk
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// Collect reads up to size rows from the source. An empty result with a nil
+// error means the source is exhausted.
+func Collect(src types.RowSource, size int) ([]types.Row, error) {
+	var chunk []types.Row
+	for len(chunk) < size && src.Next() {
+		chunk = append(chunk, src.Row())
+	}
+	if err := src.Err(); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// ProcessFunc converts one chunk of rows. index is the chunk position and
+// offset the number of rows that precede it in the stream.
+type ProcessFunc[T any] func(index, offset int, rows []types.Row) (T, error)
+
+// Ordered cuts the source into chunks of size rows, processes them on up to
+// workers goroutines and hands the results to emit in source order. At most
+// workers chunks are held in memory at any time. The first error, whether
+// from the source, process or emit, stops further chunks from being read and
+// is returned once in-flight work ends. Cancelling ctx stops reading and
+// skips chunks that have not started processing.
+func Ordered[T any](ctx context.Context, src types.RowSource, size, workers int, process ProcessFunc[T], emit func(T) error) error {
+	src = WithContext(ctx, src)
+
+	type result struct {
+		index int
+		value T
+		err   error
+	}
+
+	slots := make(chan struct{}, workers)
+	results := make(chan result, workers)
+	stop := make(chan struct{})
+
+	// readErr is written before results is closed and read after
+	var readErr error
+
+	go func() {
+		var wg sync.WaitGroup
+		index := 0
+		defer func() {
+			// A panicking source must not leave the consumer waiting
+			if r := recover(); r != nil {
+				readErr = &types.PanicError{Chunk: index, Value: r, Stack: debug.Stack()}
+			}
+			wg.Wait()
+			close(results)
+		}()
+
+		for offset := 0; ; index++ {
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				readErr = ctx.Err()
+				return
+			}
+
+			data, err := Collect(src, size)
+			if err != nil {
+				readErr = err
+				return
+			}
+			if len(data) == 0 {
+				return
+			}
+
+			wg.Add(1)
+			go func(index, offset int, data []types.Row) {
+				defer wg.Done()
+				if err := ctx.Err(); err != nil {
+					results <- result{index: index, err: err}
+					return
+				}
+				value, err := safeProcess(process, index, offset, data)
+				results <- result{index: index, value: value, err: err}
+			}(index, offset, data)
+
+			offset += len(data)
+		}
+	}()
+
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+			close(stop)
+		}
+	}
+
+	pending := make(map[int]result)
+	next := 0
+	for r := range results {
+		if firstErr != nil {
+			<-slots
+			continue
+		}
+		if r.err != nil {
+			fail(r.err)
+			// Release the slot of this result and of everything parked
+			for range pending {
+				<-slots
+			}
+			<-slots
+			continue
+		}
+
+		pending[r.index] = r
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-slots
+
+			if err := emit(ready.value); err != nil {
+				fail(err)
+				for range pending {
+					<-slots
+				}
+				break
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return readErr
+}
+
+// safeProcess runs process, converting a panic into a PanicError for the chunk
+func safeProcess[T any](process ProcessFunc[T], index, offset int, rows []types.Row) (value T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &types.PanicError{Chunk: index, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return process(index, offset, rows)
+}
+
+// contextSource stops iteration once its context is done
+type contextSource struct {
+	types.RowSource
+	ctx context.Context
+	err error
+}
+
+// WithContext returns a source that stops with the context's error once ctx
+// is done
+func WithContext(ctx context.Context, src types.RowSource) types.RowSource {
+	return &contextSource{RowSource: src, ctx: ctx}
+}
+
+func (s *contextSource) Next() bool {
+	select {
+	case <-s.ctx.Done():
+		s.err = s.ctx.Err()
+		return false
+	default:
+	}
+	return s.RowSource.Next()
+}
+
+func (s *contextSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.RowSource.Err()
+}
+
+// Peek reads up to n rows from the source and returns them together with a
+// source that yields the peeked rows again before the remaining ones
+func Peek(src types.RowSource, n int) ([]types.Row, types.RowSource, error) {
+	rows, err := Collect(src, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, &replaySource{RowSource: src, rows: rows}, nil
+}
+
+// replaySource yields buffered rows before continuing with its source
+type replaySource struct {
+	types.RowSource
+	rows    []types.Row
+	pos     int
+	row     types.Row
+	current bool
+}
+
+func (s *replaySource) Next() bool {
+	if s.pos < len(s.rows) {
+		s.row = s.rows[s.pos]
+		s.pos++
+		return true
+	}
+	// Release the buffer once it has been replayed
+	s.rows = nil
+	s.current = true
+	return s.RowSource.Next()
+}
+
+func (s *replaySource) Row() types.Row {
+	if s.current {
+		return s.RowSource.Row()
+	}
+	return s.row
+}
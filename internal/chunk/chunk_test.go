@@ -0,0 +1,262 @@
+package chunk
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// intRows returns n rows holding their own position
+func intRows(n int) []types.Row {
+	rows := make([]types.Row, n)
+	for i := range rows {
+		rows[i] = types.Row{i}
+	}
+	return rows
+}
+
+// funcSource yields rows holding their position while next reports true,
+// then stops with err
+type funcSource struct {
+	next func(i int) bool
+	i    int
+	err  error
+	done bool
+}
+
+func (s *funcSource) Next() bool {
+	if s.done || !s.next(s.i) {
+		s.done = true
+		return false
+	}
+	s.i++
+	return true
+}
+
+func (s *funcSource) Row() types.Row { return types.Row{s.i - 1} }
+func (s *funcSource) Close() error   { return nil }
+
+func (s *funcSource) Err() error {
+	if s.done {
+		return s.err
+	}
+	return nil
+}
+
+// chunkStart is the result of processing a chunk in these tests
+type chunkStart struct {
+	index, offset, first int
+}
+
+func startOf(index, offset int, rows []types.Row) (chunkStart, error) {
+	return chunkStart{index: index, offset: offset, first: rows[0][0].(int)}, nil
+}
+
+// ordered runs Ordered, failing the test if it does not return in time
+func ordered(t *testing.T, ctx context.Context, src types.RowSource, size, workers int, process ProcessFunc[chunkStart], emit func(chunkStart) error) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		done <- Ordered(ctx, src, size, workers, process, emit)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Ordered did not return: deadlock")
+		return nil
+	}
+}
+
+func TestOrderedEmitsInSourceOrder(t *testing.T) {
+	const size, chunks = 10, 20
+
+	// Earlier chunks take longer, so they finish after later ones
+	process := func(index, offset int, rows []types.Row) (chunkStart, error) {
+		time.Sleep(time.Duration(chunks-index) * time.Millisecond)
+		return startOf(index, offset, rows)
+	}
+
+	var emitted []chunkStart
+	emit := func(c chunkStart) error {
+		emitted = append(emitted, c)
+		return nil
+	}
+
+	src := types.NewSliceSource(intRows(size*chunks - 3))
+	if err := ordered(t, context.Background(), src, size, 4, process, emit); err != nil {
+		t.Fatalf("Ordered = %v", err)
+	}
+	if len(emitted) != chunks {
+		t.Fatalf("emitted %d chunks, want %d", len(emitted), chunks)
+	}
+	for i, c := range emitted {
+		if c.index != i || c.offset != i*size || c.first != i*size {
+			t.Errorf("chunk %d emitted as %+v, want index %d and offset %d", i, c, i, i*size)
+		}
+	}
+}
+
+func TestOrderedHoldsAtMostWorkersChunks(t *testing.T) {
+	const workers = 3
+	var inFlight, peak atomic.Int64
+	process := func(index, offset int, rows []types.Row) (chunkStart, error) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return startOf(index, offset, rows)
+	}
+	emit := func(chunkStart) error {
+		inFlight.Add(-1)
+		return nil
+	}
+
+	src := types.NewSliceSource(intRows(500))
+	if err := ordered(t, context.Background(), src, 5, workers, process, emit); err != nil {
+		t.Fatalf("Ordered = %v", err)
+	}
+	if p := peak.Load(); p > workers {
+		t.Fatalf("%d chunks held at once, want at most %d", p, workers)
+	}
+}
+
+func TestOrderedReturnsProcessError(t *testing.T) {
+	errBoom := errors.New("boom")
+	process := func(index, offset int, rows []types.Row) (chunkStart, error) {
+		if index == 3 {
+			return chunkStart{}, errBoom
+		}
+		return startOf(index, offset, rows)
+	}
+
+	var emitted []int
+	emit := func(c chunkStart) error {
+		emitted = append(emitted, c.index)
+		return nil
+	}
+
+	src := types.NewSliceSource(intRows(1000))
+	err := ordered(t, context.Background(), src, 10, 4, process, emit)
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("Ordered = %v, want %v", err, errBoom)
+	}
+	for _, index := range emitted {
+		if index >= 3 {
+			t.Fatalf("chunk %d emitted after chunk 3 failed", index)
+		}
+	}
+}
+
+func TestOrderedReturnsEmitError(t *testing.T) {
+	errFull := errors.New("disk full")
+	var calls int
+	emit := func(chunkStart) error {
+		calls++
+		if calls == 2 {
+			return errFull
+		}
+		return nil
+	}
+
+	src := types.NewSliceSource(intRows(1000))
+	err := ordered(t, context.Background(), src, 10, 4, startOf, emit)
+	if !errors.Is(err, errFull) {
+		t.Fatalf("Ordered = %v, want %v", err, errFull)
+	}
+	if calls != 2 {
+		t.Fatalf("emit called %d times, want 2", calls)
+	}
+}
+
+func TestOrderedReturnsSourceError(t *testing.T) {
+	errRead := errors.New("read failed")
+	src := &funcSource{next: func(i int) bool { return i < 55 }, err: errRead}
+
+	var emitted int
+	emit := func(chunkStart) error {
+		emitted++
+		return nil
+	}
+
+	err := ordered(t, context.Background(), src, 10, 4, startOf, emit)
+	if !errors.Is(err, errRead) {
+		t.Fatalf("Ordered = %v, want %v", err, errRead)
+	}
+	// The partial chunk read before the error is never processed
+	if emitted > 5 {
+		t.Fatalf("emitted %d chunks, want at most 5", emitted)
+	}
+}
+
+func TestOrderedStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var read atomic.Int64
+	src := &funcSource{next: func(int) bool {
+		read.Add(1)
+		return true
+	}}
+
+	var emitted int
+	emit := func(chunkStart) error {
+		emitted++
+		if emitted == 2 {
+			cancel()
+		}
+		return nil
+	}
+
+	err := ordered(t, ctx, src, 10, 4, startOf, emit)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Ordered = %v, want context.Canceled", err)
+	}
+	after := read.Load()
+	time.Sleep(10 * time.Millisecond)
+	if read.Load() != after {
+		t.Fatal("source read after Ordered returned")
+	}
+}
+
+func TestOrderedRecoversSourcePanic(t *testing.T) {
+	src := &funcSource{next: func(i int) bool {
+		if i == 25 {
+			panic("source broke")
+		}
+		return true
+	}}
+
+	err := ordered(t, context.Background(), src, 10, 2, startOf, func(chunkStart) error { return nil })
+	var panicErr *types.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Ordered = %v, want a PanicError", err)
+	}
+	if panicErr.Chunk != 2 || panicErr.Value != "source broke" {
+		t.Fatalf("PanicError = chunk %d value %v, want chunk 2 value %q", panicErr.Chunk, panicErr.Value, "source broke")
+	}
+}
+
+func TestOrderedRecoversProcessPanic(t *testing.T) {
+	process := func(index, offset int, rows []types.Row) (chunkStart, error) {
+		if index == 1 {
+			panic("process broke")
+		}
+		return startOf(index, offset, rows)
+	}
+
+	src := types.NewSliceSource(intRows(100))
+	err := ordered(t, context.Background(), src, 10, 4, process, func(chunkStart) error { return nil })
+	var panicErr *types.PanicError
+	if !errors.As(err, &panicErr) || panicErr.Chunk != 1 {
+		t.Fatalf("Ordered = %v, want a PanicError of chunk 1", err)
+	}
+}
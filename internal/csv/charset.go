@@ -0,0 +1,183 @@
+package csv
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// charsets maps the names and aliases of the supported non-UTF-8 charsets
+// to their canonical name and encoding
+var charsets = map[string]struct {
+	name     string
+	encoding encoding.Encoding
+}{
+	types.CharsetWindows1252: {types.CharsetWindows1252, charmap.Windows1252},
+	"cp1252":                 {types.CharsetWindows1252, charmap.Windows1252},
+	types.CharsetShiftJIS:    {types.CharsetShiftJIS, japanese.ShiftJIS},
+	"shift_jis":              {types.CharsetShiftJIS, japanese.ShiftJIS},
+	"sjis":                   {types.CharsetShiftJIS, japanese.ShiftJIS},
+}
+
+// transliterations are the replacements of common characters that do not
+// decompose into representable ones
+var transliterations = map[rune]string{
+	'‘': "'", '’': "'", '‚': ",", '‛': "'", '“': `"`, '”': `"`, '„': `"`,
+	'‐': "-", '‑': "-", '‒': "-", '–': "-", '—': "-", '−': "-",
+	'…': "...", '•': "*", '€': "EUR", '£': "GBP", '©': "(c)", '®': "(R)", '™': "TM",
+	'×': "x", '÷': "/", '\u00A0': " ", '\u2009': " ", '\u202F': " ",
+	'ß': "ss", 'Æ': "AE", 'æ': "ae", 'Ø': "O", 'ø': "o", 'Œ': "OE", 'œ': "oe",
+	'Ł': "L", 'ł': "l", 'Đ': "D", 'đ': "d", 'Þ': "Th", 'þ': "th", 'ı': "i",
+}
+
+// transcoder fits the text of cells to a charset. Text is still handled
+// as UTF-8 and only converted to the charset when written, after every
+// character has been made representable.
+type transcoder struct {
+	name      string
+	encoding  encoding.Encoding
+	encoder   *encoding.Encoder
+	policy    types.UnencodablePolicy
+	encodable map[rune]bool
+	lossy     int // cells changed to fit the charset
+}
+
+// newTranscoder returns the transcoder of opts, or nil for UTF-8 output
+func newTranscoder(opts types.CSVOptions) (*transcoder, error) {
+	name := strings.ToLower(opts.Charset)
+	if name == "" || name == types.CharsetUTF8 || name == "utf8" {
+		return nil, nil
+	}
+	charset, ok := charsets[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported charset %q: expected utf-8, windows-1252 or shift-jis", opts.Charset)
+	}
+
+	policy := opts.Unencodable
+	switch policy {
+	case "":
+		policy = types.UnencodableError
+	case types.UnencodableError, types.UnencodableReplace, types.UnencodableTransliterate:
+	default:
+		return nil, fmt.Errorf("invalid unencodable character policy %q: expected error, replace or transliterate", policy)
+	}
+
+	return &transcoder{
+		name:      charset.name,
+		encoding:  charset.encoding,
+		encoder:   charset.encoding.NewEncoder(),
+		policy:    policy,
+		encodable: make(map[rune]bool),
+	}, nil
+}
+
+// canEncode reports whether the charset represents r
+func (t *transcoder) canEncode(r rune) bool {
+	if r < utf8.RuneSelf {
+		return true
+	}
+	if ok, cached := t.encodable[r]; cached {
+		return ok
+	}
+
+	var src [utf8.UTFMax]byte
+	var dst [8]byte
+	n := utf8.EncodeRune(src[:], r)
+	t.encoder.Reset()
+	_, _, err := t.encoder.Transform(dst[:], src[:n], true)
+	ok := err == nil && r != utf8.RuneError
+	t.encodable[r] = ok
+	return ok
+}
+
+// cell returns field with the characters the charset cannot represent,
+// and invalid UTF-8, handled by the policy. record is the 1-based number
+// of the record for errors.
+func (t *transcoder) cell(field string, record int) (string, error) {
+	start := 0
+	for start < len(field) && field[start] < utf8.RuneSelf {
+		start++
+	}
+	if start == len(field) {
+		return field, nil
+	}
+	if t.representable(field[start:]) {
+		return field, nil
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(field))
+	sb.WriteString(field[:start])
+	rest := field[start:]
+	for i, r := range rest {
+		invalid := false
+		if r == utf8.RuneError {
+			_, size := utf8.DecodeRuneInString(rest[i:])
+			invalid = size == 1
+		}
+		if !invalid && t.canEncode(r) {
+			sb.WriteRune(r)
+			continue
+		}
+
+		switch t.policy {
+		case types.UnencodableReplace:
+			sb.WriteByte('?')
+		case types.UnencodableTransliterate:
+			sb.WriteString(t.transliterate(r, invalid))
+		default:
+			if invalid {
+				return "", fmt.Errorf("record %d: invalid UTF-8 cannot be encoded in %s", record, t.name)
+			}
+			return "", fmt.Errorf("record %d: character %q (U+%04X) cannot be encoded in %s", record, r, r, t.name)
+		}
+	}
+	t.lossy++
+	return sb.String(), nil
+}
+
+// representable reports whether s is valid UTF-8 the charset represents
+func (t *transcoder) representable(s string) bool {
+	for i, r := range s {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				return false
+			}
+		}
+		if !t.canEncode(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// transliterate returns the closest text to r the charset represents: a
+// known replacement, or r's compatibility decomposition without accents.
+// It falls back to '?'.
+func (t *transcoder) transliterate(r rune, invalid bool) string {
+	if invalid {
+		return "?"
+	}
+	if s, ok := transliterations[r]; ok && t.representable(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	for _, d := range norm.NFKD.String(string(r)) {
+		if !unicode.Is(unicode.Mn, d) {
+			sb.WriteRune(d)
+		}
+	}
+	if s := sb.String(); s != "" && t.representable(s) {
+		return s
+	}
+	return "?"
+}
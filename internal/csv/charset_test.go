@@ -0,0 +1,235 @@
+package csv
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// writeCharset exports rows with opts through every writer, checking that
+// they agree on the output and the changed cells, and returns them
+func writeCharset(t *testing.T, opts types.CSVOptions, headers []string, rows []types.Row) ([]byte, int, error) {
+	t.Helper()
+	var part bytes.Buffer
+	lossy, partErr := WritePart(&part, headers, rows, &types.SplitZipConfig{IncludeHeaders: true, CSV: opts})
+
+	for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+		var buf bytes.Buffer
+		config := &types.ExportConfig{Mode: mode, Workers: 2, ChunkSize: 1, Output: &buf, CSV: opts}
+		writer := NewWriter(config)
+		err := writer.Write(context.Background(), headers, types.NewSliceSource(rows))
+		if (err == nil) != (partErr == nil) || err != nil && !strings.HasSuffix(err.Error(), strings.TrimPrefix(partErr.Error(), "failed to write row: ")) {
+			t.Fatalf("%s: Write = %v, WritePart = %v", mode, err, partErr)
+		}
+		if err == nil && (!bytes.Equal(buf.Bytes(), part.Bytes()) || writer.LossyCells() != lossy) {
+			t.Fatalf("%s: wrote %q with %d lossy cells, part %q with %d",
+				mode, buf.Bytes(), writer.LossyCells(), part.Bytes(), lossy)
+		}
+	}
+	return part.Bytes(), lossy, partErr
+}
+
+// decode returns data decoded from enc into UTF-8
+func decode(t *testing.T, enc encoding.Encoding, data []byte) string {
+	t.Helper()
+	text, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return string(text)
+}
+
+func TestCharsetRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     types.CSVOptions
+		encoding encoding.Encoding
+		rows     []types.Row
+		want     string
+	}{
+		{
+			name:     "windows-1252",
+			opts:     types.CSVOptions{Charset: "windows-1252", Delimiter: ';'},
+			encoding: charmap.Windows1252,
+			rows:     []types.Row{{"Café", "naïve €5 – “ok”"}, {"Øre", 12.5}},
+			want:     "name;note\nCafé;naïve €5 – “ok”\nØre;12.5\n",
+		},
+		{
+			name:     "cp1252 alias",
+			opts:     types.CSVOptions{Charset: "CP1252"},
+			encoding: charmap.Windows1252,
+			rows:     []types.Row{{"Müller", "straße"}},
+			want:     "name,note\nMüller,straße\n",
+		},
+		{
+			name:     "shift-jis",
+			opts:     types.CSVOptions{Charset: "shift-jis", CRLF: true},
+			encoding: japanese.ShiftJIS,
+			rows:     []types.Row{{"東京", "ﾃｽﾄ, テスト"}, {"大阪", "ー"}},
+			want:     "name,note\r\n東京,\"ﾃｽﾄ, テスト\"\r\n大阪,ー\r\n",
+		},
+		{
+			name:     "sjis alias",
+			opts:     types.CSVOptions{Charset: "SJIS", Quoting: types.QuoteAll},
+			encoding: japanese.ShiftJIS,
+			rows:     []types.Row{{"名前", "表"}},
+			want:     "\"name\",\"note\"\n\"名前\",\"表\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, lossy, err := writeCharset(t, tt.opts, []string{"name", "note"}, tt.rows)
+			if err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if bytes.Equal(data, []byte(tt.want)) {
+				t.Fatal("output was not transcoded")
+			}
+			if got := decode(t, tt.encoding, data); got != tt.want {
+				t.Fatalf("decoded output:\n%q\nwant:\n%q", got, tt.want)
+			}
+			if lossy != 0 {
+				t.Fatalf("%d lossy cells, want 0", lossy)
+			}
+		})
+	}
+}
+
+func TestUTF8CharsetIsUnchanged(t *testing.T) {
+	rows := []types.Row{{"日本", "ő €"}}
+	for _, charset := range []string{"", "utf-8", "UTF8"} {
+		data, lossy, err := writeCharset(t, types.CSVOptions{Charset: charset, BOM: true}, []string{"a", "b"}, rows)
+		if err != nil {
+			t.Fatalf("%q: write: %v", charset, err)
+		}
+		if want := "\uFEFFa,b\n日本,ő €\n"; string(data) != want || lossy != 0 {
+			t.Fatalf("%q: wrote %q with %d lossy cells, want %q", charset, data, lossy, want)
+		}
+	}
+}
+
+func TestUnencodableCharacters(t *testing.T) {
+	headers := []string{"name", "note"}
+	rows := []types.Row{
+		{"Łódź", "ok"},
+		{"plain", "smile 😀"},
+		{"Ærø", "Œuvre"},
+		{"bad \xff byte", "fine"},
+	}
+	tests := []struct {
+		name    string
+		charset string
+		policy  types.UnencodablePolicy
+		want    string
+		lossy   int
+	}{
+		{
+			name:    "replace",
+			charset: "windows-1252",
+			policy:  types.UnencodableReplace,
+			want:    "name,note\n?ód?,ok\nplain,smile ?\nÆrø,Œuvre\nbad ? byte,fine\n",
+			lossy:   3,
+		},
+		{
+			name:    "transliterate",
+			charset: "windows-1252",
+			policy:  types.UnencodableTransliterate,
+			want:    "name,note\nLódz,ok\nplain,smile ?\nÆrø,Œuvre\nbad ? byte,fine\n",
+			lossy:   3,
+		},
+		{
+			name:    "transliterate shift-jis",
+			charset: "shift-jis",
+			policy:  types.UnencodableTransliterate,
+			want:    "name,note\nLodz,ok\nplain,smile ?\nAEro,OEuvre\nbad ? byte,fine\n",
+			lossy:   5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := types.CSVOptions{Charset: tt.charset, Unencodable: tt.policy}
+			data, lossy, err := writeCharset(t, opts, headers, rows)
+			if err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			enc := encoding.Encoding(charmap.Windows1252)
+			if tt.charset == "shift-jis" {
+				enc = japanese.ShiftJIS
+			}
+			if got := decode(t, enc, data); got != tt.want {
+				t.Fatalf("decoded output:\n%q\nwant:\n%q", got, tt.want)
+			}
+			if lossy != tt.lossy {
+				t.Fatalf("%d lossy cells, want %d", lossy, tt.lossy)
+			}
+		})
+	}
+}
+
+func TestUnencodableCharacterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy types.UnencodablePolicy
+		rows   []types.Row
+		want   string
+	}{
+		{
+			name: "default policy",
+			rows: []types.Row{{"ok"}, {"Łódź"}},
+			want: "record 3: character 'Ł' (U+0141) cannot be encoded in windows-1252",
+		},
+		{
+			name:   "error policy",
+			policy: types.UnencodableError,
+			rows:   []types.Row{{"smile 😀"}},
+			want:   "record 2: character '😀' (U+1F600) cannot be encoded in windows-1252",
+		},
+		{
+			name:   "invalid UTF-8",
+			policy: types.UnencodableError,
+			rows:   []types.Row{{"bad \xff"}},
+			want:   "record 2: invalid UTF-8 cannot be encoded in windows-1252",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := types.CSVOptions{Charset: "windows-1252", Unencodable: tt.policy}
+			_, _, err := writeCharset(t, opts, []string{"text"}, tt.rows)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("write = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidCharsetOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts types.CSVOptions
+		want string
+	}{
+		{"unsupported charset", types.CSVOptions{Charset: "ebcdic"}, `unsupported charset "ebcdic"`},
+		{"policy", types.CSVOptions{Charset: "shift-jis", Unencodable: "drop"}, `invalid unencodable character policy "drop"`},
+		{"bom", types.CSVOptions{Charset: "windows-1252", BOM: true}, "byte order mark can only be written in UTF-8, not windows-1252"},
+		{"bom alias", types.CSVOptions{Charset: "sjis", BOM: true}, "byte order mark can only be written in UTF-8, not shift-jis"},
+		{"delimiter", types.CSVOptions{Charset: "windows-1252", Delimiter: '→'}, `CSV delimiter '→' cannot be encoded in windows-1252`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckOptions(tt.opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("CheckOptions = %v, want an error containing %q", err, tt.want)
+			}
+			data, _, err := writeCharset(t, tt.opts, []string{"a"}, []types.Row{{"b"}})
+			if err == nil || len(data) != 0 {
+				t.Fatalf("wrote %q with error %v", data, err)
+			}
+		})
+	}
+}
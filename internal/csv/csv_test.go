@@ -0,0 +1,266 @@
+package csv
+
+import (
+	"bytes"
+	"context"
+	stdcsv "encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+var (
+	dialectHeaders = []string{"id", "text"}
+	dialectRows    = []types.Row{
+		{1, "plain"},
+		{2, "a;b,c"},
+		{3, `say "hi"`},
+		{4, "two\nlines"},
+		{5, `C:\dir`},
+		{6, "tab\there"},
+		{7, " padded"},
+		{8, ""},
+	}
+)
+
+// write exports dialectRows with opts through every writer and checks that
+// they agree, returning the output
+func write(t *testing.T, opts types.CSVOptions) (string, error) {
+	t.Helper()
+	var part bytes.Buffer
+	_, partErr := WritePart(&part, dialectHeaders, dialectRows, &types.SplitZipConfig{IncludeHeaders: true, CSV: opts})
+
+	for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+		var buf bytes.Buffer
+		config := &types.ExportConfig{Mode: mode, Workers: 3, ChunkSize: 3, Output: &buf, CSV: opts}
+		err := NewWriter(config).Write(context.Background(), dialectHeaders, types.NewSliceSource(dialectRows))
+		if (err == nil) != (partErr == nil) {
+			t.Fatalf("%s: Write = %v, WritePart = %v", mode, err, partErr)
+		}
+		if buf.String() != part.String() {
+			t.Fatalf("%s output differs from the part:\n%q\n%q", mode, buf.String(), part.String())
+		}
+	}
+	return part.String(), partErr
+}
+
+func TestDialects(t *testing.T) {
+	tests := []struct {
+		name string
+		opts types.CSVOptions
+		want string
+	}{
+		{
+			name: "default",
+			want: "id,text\n1,plain\n2,\"a;b,c\"\n3,\"say \"\"hi\"\"\"\n4,\"two\nlines\"\n5,C:\\dir\n6,tab\there\n7,\" padded\"\n8,\n",
+		},
+		{
+			name: "semicolon",
+			opts: types.CSVOptions{Delimiter: ';'},
+			want: "id;text\n1;plain\n2;\"a;b,c\"\n3;\"say \"\"hi\"\"\"\n4;\"two\nlines\"\n5;C:\\dir\n6;tab\there\n7;\" padded\"\n8;\n",
+		},
+		{
+			name: "tab",
+			opts: types.CSVOptions{Delimiter: '\t'},
+			want: "id\ttext\n1\tplain\n2\ta;b,c\n3\t\"say \"\"hi\"\"\"\n4\t\"two\nlines\"\n5\tC:\\dir\n6\t\"tab\there\"\n7\t\" padded\"\n8\t\n",
+		},
+		{
+			name: "quote all",
+			opts: types.CSVOptions{Quoting: types.QuoteAll},
+			want: "\"id\",\"text\"\n\"1\",\"plain\"\n\"2\",\"a;b,c\"\n\"3\",\"say \"\"hi\"\"\"\n\"4\",\"two\nlines\"\n\"5\",\"C:\\dir\"\n\"6\",\"tab\there\"\n\"7\",\" padded\"\n\"8\",\"\"\n",
+		},
+		{
+			name: "minimal quoting",
+			opts: types.CSVOptions{Quoting: types.QuoteMinimal, Escape: types.EscapeDouble},
+			want: "id,text\n1,plain\n2,\"a;b,c\"\n3,\"say \"\"hi\"\"\"\n4,\"two\nlines\"\n5,C:\\dir\n6,tab\there\n7,\" padded\"\n8,\n",
+		},
+		{
+			name: "crlf",
+			opts: types.CSVOptions{CRLF: true},
+			want: "id,text\r\n1,plain\r\n2,\"a;b,c\"\r\n3,\"say \"\"hi\"\"\"\r\n4,\"two\r\nlines\"\r\n5,C:\\dir\r\n6,tab\there\r\n7,\" padded\"\r\n8,\r\n",
+		},
+		{
+			name: "bom",
+			opts: types.CSVOptions{BOM: true},
+			want: "\xEF\xBB\xBFid,text\n1,plain\n2,\"a;b,c\"\n3,\"say \"\"hi\"\"\"\n4,\"two\nlines\"\n5,C:\\dir\n6,tab\there\n7,\" padded\"\n8,\n",
+		},
+		{
+			name: "backslash escape",
+			opts: types.CSVOptions{Escape: types.EscapeBackslash},
+			want: "id,text\n1,plain\n2,\"a;b,c\"\n3,\"say \\\"hi\\\"\"\n4,\"two\nlines\"\n5,\"C:\\\\dir\"\n6,tab\there\n7,\" padded\"\n8,\n",
+		},
+		{
+			name: "european excel",
+			opts: types.CSVOptions{Delimiter: ';', CRLF: true, BOM: true, Quoting: types.QuoteAll},
+			want: "\xEF\xBB\xBF\"id\";\"text\"\r\n\"1\";\"plain\"\r\n\"2\";\"a;b,c\"\r\n\"3\";\"say \"\"hi\"\"\"\r\n\"4\";\"two\r\nlines\"\r\n\"5\";\"C:\\dir\"\r\n\"6\";\"tab\there\"\r\n\"7\";\" padded\"\r\n\"8\";\"\"\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := write(t, tt.opts)
+			if err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("output:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultDialectMatchesEncodingCSV(t *testing.T) {
+	records := [][]string{
+		dialectHeaders,
+		{"a\rb", "\\.", "\r\n", "\"", "\u00a0nbsp"},
+		{"", " ", "x y", "ünï", "end\n"},
+	}
+
+	var want bytes.Buffer
+	std := stdcsv.NewWriter(&want)
+	if err := std.WriteAll(records); err != nil {
+		t.Fatalf("encoding/csv: %v", err)
+	}
+
+	var got bytes.Buffer
+	rw, err := NewRecordWriter(&got, 16, types.CSVOptions{})
+	if err != nil {
+		t.Fatalf("NewRecordWriter: %v", err)
+	}
+	for _, record := range records {
+		if err := rw.Write(record); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := rw.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got.String() != want.String() {
+		t.Fatalf("output:\n%q\nencoding/csv:\n%q", got.String(), want.String())
+	}
+}
+
+func TestInvalidDialects(t *testing.T) {
+	tests := []struct {
+		name string
+		opts types.CSVOptions
+		want string
+	}{
+		{"quote delimiter", types.CSVOptions{Delimiter: '"'}, "invalid CSV delimiter"},
+		{"newline delimiter", types.CSVOptions{Delimiter: '\n'}, "invalid CSV delimiter"},
+		{"carriage return delimiter", types.CSVOptions{Delimiter: '\r'}, "invalid CSV delimiter"},
+		{"invalid rune", types.CSVOptions{Delimiter: -1}, "invalid CSV delimiter"},
+		{"backslash delimiter and escape", types.CSVOptions{Delimiter: '\\', Escape: types.EscapeBackslash}, "invalid CSV delimiter"},
+		{"quoting", types.CSVOptions{Quoting: "some"}, `invalid CSV quoting "some"`},
+		{"escape", types.CSVOptions{Escape: "html"}, `invalid CSV escape "html"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := write(t, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("write = %v, want an error containing %q", err, tt.want)
+			}
+			if got != "" {
+				t.Fatalf("%d bytes written for an invalid dialect", len(got))
+			}
+		})
+	}
+
+	if err := CheckOptions(types.CSVOptions{Delimiter: '\\'}); err != nil {
+		t.Fatalf("backslash delimiter with doubled quotes: %v", err)
+	}
+}
+
+// writeProtected exports rows with protect through every writer, checking
+// that they agree, and returns the output
+func writeProtected(t *testing.T, protect types.InjectionOptions, headers []string, rows []types.Row) (string, error) {
+	t.Helper()
+	var part bytes.Buffer
+	_, partErr := WritePart(&part, headers, rows, &types.SplitZipConfig{IncludeHeaders: true, Injection: protect})
+
+	for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+		var buf bytes.Buffer
+		config := &types.ExportConfig{Mode: mode, Workers: 2, ChunkSize: 1, Output: &buf, Injection: protect}
+		err := NewWriter(config).Write(context.Background(), headers, types.NewSliceSource(rows))
+		if (err == nil) != (partErr == nil) || err != nil && err.Error() != partErr.Error() {
+			t.Fatalf("%s: Write = %v, WritePart = %v", mode, err, partErr)
+		}
+		if err == nil && buf.String() != part.String() {
+			t.Fatalf("%s output differs from the part:\n%q\n%q", mode, buf.String(), part.String())
+		}
+	}
+	return part.String(), partErr
+}
+
+func TestInjection(t *testing.T) {
+	headers := []string{"=name", "comment", "amount"}
+	rows := []types.Row{
+		{"=HYPERLINK(\"http://x\")", "@SUM(1)", -12.5},
+		{"+cmd", "-cmd", "-3"},
+		{"plain", "\t=1", "=1"},
+	}
+	tests := []struct {
+		name    string
+		protect types.InjectionOptions
+		want    string
+	}{
+		{
+			name: "off",
+			want: "=name,comment,amount\n\"=HYPERLINK(\"\"http://x\"\")\",@SUM(1),-12.5\n+cmd,-cmd,-3\nplain,\"\t=1\",=1\n",
+		},
+		{
+			name:    "prefix",
+			protect: types.InjectionOptions{Policy: types.InjectionPrefix},
+			want:    "'=name,comment,amount\n\"'=HYPERLINK(\"\"http://x\"\")\",'@SUM(1),-12.5\n'+cmd,'-cmd,-3\nplain,'\t=1,'=1\n",
+		},
+		{
+			name:    "strip",
+			protect: types.InjectionOptions{Policy: types.InjectionStrip},
+			want:    "name,comment,amount\n\"HYPERLINK(\"\"http://x\"\")\",SUM(1),-12.5\ncmd,cmd,-3\nplain,1,1\n",
+		},
+		{
+			name: "per column",
+			protect: types.InjectionOptions{
+				Policy:  types.InjectionPrefix,
+				Columns: map[string]types.InjectionPolicy{"=name": types.InjectionOff, "amount": types.InjectionStrip},
+			},
+			want: "=name,comment,amount\n\"=HYPERLINK(\"\"http://x\"\")\",'@SUM(1),-12.5\n+cmd,'-cmd,-3\nplain,'\t=1,1\n",
+		},
+		{
+			name:    "column only",
+			protect: types.InjectionOptions{Columns: map[string]types.InjectionPolicy{"comment": types.InjectionPrefix}},
+			want:    "=name,comment,amount\n\"=HYPERLINK(\"\"http://x\"\")\",'@SUM(1),-12.5\n+cmd,'-cmd,-3\nplain,'\t=1,=1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := writeProtected(t, tt.protect, headers, rows)
+			if err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("output:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInjectionReject(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    string
+	}{
+		{"header", []string{"=name", "comment"}, `header: column "=name": text starting with '='`},
+		{"row", []string{"name", "comment"}, `row 2: column "comment": text starting with '-'`},
+	}
+	rows := []types.Row{{"a", "b"}, {"c", "-d"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := writeProtected(t, types.InjectionOptions{Policy: types.InjectionReject}, tt.headers, rows)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("write = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package csv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/transform"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// byteOrderMark is the UTF-8 encoding of U+FEFF
+const byteOrderMark = "\uFEFF"
+
+// RecordWriter writes records in a CSV dialect and charset. With zero
+// options its output is identical to encoding/csv's.
+type RecordWriter struct {
+	w         *bufio.Writer
+	encoded   io.WriteCloser // transcodes to the charset, nil for UTF-8
+	text      *transcoder
+	records   int
+	comma     rune
+	quoteAll  bool
+	backslash bool
+	crlf      bool
+	special   string // characters escaped inside quoted fields
+}
+
+// NewRecordWriter returns a RecordWriter writing to w through a buffer of
+// size bytes, starting with the byte order mark if the dialect has one.
+// Flush must be called after the last record.
+func NewRecordWriter(w io.Writer, size int, opts types.CSVOptions) (*RecordWriter, error) {
+	if err := CheckOptions(opts); err != nil {
+		return nil, err
+	}
+	text, err := newTranscoder(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rw := &RecordWriter{
+		text:      text,
+		comma:     delimiter(opts),
+		quoteAll:  opts.Quoting == types.QuoteAll,
+		backslash: opts.Escape == types.EscapeBackslash,
+		crlf:      opts.CRLF,
+		special:   "\"\r\n",
+	}
+	if rw.backslash {
+		rw.special = "\"\\\r\n"
+	}
+
+	if text != nil {
+		rw.encoded = transform.NewWriter(w, text.encoding.NewEncoder())
+		w = rw.encoded
+	}
+	rw.w = bufio.NewWriterSize(w, size)
+
+	if opts.BOM {
+		if _, err := rw.w.WriteString(byteOrderMark); err != nil {
+			return nil, err
+		}
+	}
+	return rw, nil
+}
+
+// CheckOptions reports whether opts describe a valid dialect
+func CheckOptions(opts types.CSVOptions) error {
+	comma := delimiter(opts)
+	if comma == '"' || comma == '\r' || comma == '\n' || comma == utf8.RuneError || !utf8.ValidRune(comma) ||
+		comma == '\\' && opts.Escape == types.EscapeBackslash {
+		return fmt.Errorf("invalid CSV delimiter %q", comma)
+	}
+
+	switch opts.Quoting {
+	case "", types.QuoteMinimal, types.QuoteAll:
+	default:
+		return fmt.Errorf("invalid CSV quoting %q: expected minimal or all", opts.Quoting)
+	}
+
+	switch opts.Escape {
+	case "", types.EscapeDouble, types.EscapeBackslash:
+	default:
+		return fmt.Errorf("invalid CSV escape %q: expected double or backslash", opts.Escape)
+	}
+
+	text, err := newTranscoder(opts)
+	if err != nil {
+		return err
+	}
+	if text != nil {
+		if !text.canEncode(comma) {
+			return fmt.Errorf("CSV delimiter %q cannot be encoded in %s", comma, text.name)
+		}
+		if opts.BOM {
+			return fmt.Errorf("a byte order mark can only be written in UTF-8, not %s", text.name)
+		}
+	}
+	return nil
+}
+
+// delimiter returns the field delimiter of opts
+func delimiter(opts types.CSVOptions) rune {
+	if opts.Delimiter == 0 {
+		return ','
+	}
+	return opts.Delimiter
+}
+
+// Write writes one record. Errors are those of the underlying writer, or
+// of a character the charset cannot represent.
+func (rw *RecordWriter) Write(record []string) error {
+	rw.records++
+	for n, field := range record {
+		if rw.text != nil {
+			var err error
+			if field, err = rw.text.cell(field, rw.records); err != nil {
+				return err
+			}
+		}
+		if n > 0 {
+			rw.w.WriteRune(rw.comma)
+		}
+
+		if !rw.quoteAll && !rw.fieldNeedsQuotes(field) {
+			rw.w.WriteString(field)
+			continue
+		}
+
+		rw.w.WriteByte('"')
+		for len(field) > 0 {
+			i := strings.IndexAny(field, rw.special)
+			if i < 0 {
+				i = len(field)
+			}
+			rw.w.WriteString(field[:i])
+			field = field[i:]
+
+			if len(field) > 0 {
+				switch field[0] {
+				case '"':
+					if rw.backslash {
+						rw.w.WriteString(`\"`)
+					} else {
+						rw.w.WriteString(`""`)
+					}
+				case '\\':
+					rw.w.WriteString(`\\`)
+				case '\r':
+					if !rw.crlf {
+						rw.w.WriteByte('\r')
+					}
+				case '\n':
+					if rw.crlf {
+						rw.w.WriteString("\r\n")
+					} else {
+						rw.w.WriteByte('\n')
+					}
+				}
+				field = field[1:]
+			}
+		}
+		rw.w.WriteByte('"')
+	}
+
+	// bufio errors are sticky, so the last write reports any failure
+	var err error
+	if rw.crlf {
+		_, err = rw.w.WriteString("\r\n")
+	} else {
+		err = rw.w.WriteByte('\n')
+	}
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer
+func (rw *RecordWriter) Flush() error {
+	if err := rw.w.Flush(); err != nil {
+		return fmt.Errorf("buffer flush error: %w", err)
+	}
+	if rw.encoded != nil {
+		if err := rw.encoded.Close(); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+	}
+	return nil
+}
+
+// LossyCells returns the number of cells changed because the charset
+// cannot represent some of their characters
+func (rw *RecordWriter) LossyCells() int {
+	if rw.text == nil {
+		return 0
+	}
+	return rw.text.lossy
+}
+
+// fieldNeedsQuotes follows encoding/csv: fields containing the delimiter,
+// a quote or a line break, starting with white space or equal to \. are
+// quoted, as are fields containing a backslash when it escapes.
+func (rw *RecordWriter) fieldNeedsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if field == `\.` {
+		return true
+	}
+	if strings.ContainsRune(field, rw.comma) || strings.ContainsAny(field, rw.special) {
+		return true
+	}
+
+	r1, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(r1)
+}
@@ -0,0 +1,82 @@
+package csv
+
+import (
+	"fmt"
+
+	"github.com/turbo-export-engine/internal/injection"
+	"github.com/turbo-export-engine/internal/values"
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// formatter turns rows into CSV fields, writing null values as the null
+// text and protecting the others against formula injection
+type formatter struct {
+	values values.Formatter
+	guard  *injection.Guard
+	null   string
+}
+
+func newFormatter(headers []string, protect types.InjectionOptions, missing types.MissingOptions, numbers types.NumberOptions) (*formatter, error) {
+	format, err := values.NewFormatter(numbers)
+	if err != nil {
+		return nil, err
+	}
+	guard, err := injection.NewGuard(protect, headers)
+	if err != nil {
+		return nil, err
+	}
+	return &formatter{values: format, guard: guard, null: missing.Null}, nil
+}
+
+// writeHeaders writes the header record, if any
+func (f *formatter) writeHeaders(csvWriter *RecordWriter, headers []string) error {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	record := headers
+	if f.guard != nil {
+		record = make([]string, len(headers))
+		for i, name := range headers {
+			field, err := f.guard.Cell(i, name)
+			if err != nil {
+				return fmt.Errorf("header: %w", err)
+			}
+			record[i] = field
+		}
+	}
+	if err := csvWriter.Write(record); err != nil {
+		return fmt.Errorf("failed to write headers: %w", err)
+	}
+	return nil
+}
+
+// rows formats rows preceded by offset rows in the stream
+func (f *formatter) rows(offset int, rows []types.Row) ([][]string, error) {
+	records := make([][]string, len(rows))
+	for i, row := range rows {
+		record, err := f.row(row)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", offset+i+1, err)
+		}
+		records[i] = record
+	}
+	return records, nil
+}
+
+// row formats the values of one row
+func (f *formatter) row(row types.Row) ([]string, error) {
+	record := make([]string, len(row))
+	for i, cell := range row {
+		if cell == nil {
+			record[i] = f.null
+			continue
+		}
+		field, err := f.guard.Cell(i, f.values.Text(cell))
+		if err != nil {
+			return nil, err
+		}
+		record[i] = field
+	}
+	return record, nil
+}
@@ -0,0 +1,45 @@
+package csv
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// WritePart writes rows as a complete CSV file to w. It backs the CSV parts
+// of split exports, so parts share the dialect, value formatting and
+// injection protection of CSV exports; the splitter has fitted the rows to the
+// headers already. It returns the number of cells changed to fit the
+// charset.
+func WritePart(w io.Writer, headers []string, rows []types.Row, config *types.SplitZipConfig) (int, error) {
+	format, err := newFormatter(headers, config.Injection, config.Missing, config.Numbers)
+	if err != nil {
+		return 0, err
+	}
+	csvWriter, err := NewRecordWriter(w, 64*1024, config.CSV)
+	if err != nil {
+		return 0, err
+	}
+
+	if config.IncludeHeaders {
+		if err := format.writeHeaders(csvWriter, headers); err != nil {
+			return 0, err
+		}
+	}
+
+	for i, row := range rows {
+		record, err := format.row(row)
+		if err != nil {
+			return 0, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return 0, fmt.Errorf("failed to write row: %w", err)
+		}
+	}
+
+	if err := csvWriter.Flush(); err != nil {
+		return 0, err
+	}
+	return csvWriter.LossyCells(), nil
+}
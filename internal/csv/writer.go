@@ -1,12 +1,13 @@
 package csv
 
 import (
-	"bufio"
-	"encoding/csv"
+	"context"
 	"fmt"
-	"os"
 	"sync"
 
+	"github.com/turbo-export-engine/internal/chunk"
+	"github.com/turbo-export-engine/internal/missing"
+	"github.com/turbo-export-engine/internal/output"
 	"github.com/turbo-export-engine/pkg/types"
 )
 
@@ -14,6 +15,7 @@ import (
 type Writer struct {
 	config *types.ExportConfig
 	mu     sync.Mutex
+	lossy  int
 }
 
 // NewWriter creates a new CSV writer
@@ -23,43 +25,59 @@ func NewWriter(config *types.ExportConfig) *Writer {
 	}
 }
 
-// WriteSync writes rows synchronously without workers
-func (w *Writer) WriteSync(headers []string, rows []types.Row) error {
-	file, err := os.Create(w.config.OutputPath)
+// WriteSync writes rows synchronously without workers. The output file is
+// removed if writing fails or ctx is cancelled.
+func (w *Writer) WriteSync(ctx context.Context, headers []string, src types.RowSource) error {
+	src, err := missing.Fit(chunk.WithContext(ctx, src), headers, w.config.Missing)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
+	}
+	format, err := newFormatter(headers, w.config.Injection, w.config.Missing, w.config.Numbers)
+	if err != nil {
+		return err
 	}
-	defer file.Close()
 
-	buffered := bufio.NewWriterSize(file, 64*1024)
-	defer buffered.Flush()
+	file, err := output.Open(w.config.OutputPath, w.config.Output)
+	if err != nil {
+		return err
+	}
+	defer file.Discard()
 
-	csvWriter := csv.NewWriter(buffered)
-	defer csvWriter.Flush()
+	csvWriter, err := NewRecordWriter(file, 64*1024, w.config.CSV)
+	if err != nil {
+		return err
+	}
 
 	// Write headers
-	if len(headers) > 0 {
-		if err := csvWriter.Write(headers); err != nil {
-			return fmt.Errorf("failed to write headers: %w", err)
-		}
+	if err := format.writeHeaders(csvWriter, headers); err != nil {
+		return err
 	}
 
 	// Write rows
-	for _, row := range rows {
-		record := make([]string, len(row))
-		for i, cell := range row {
-			record[i] = fmt.Sprintf("%v", cell)
+	for rowNum := 1; src.Next(); rowNum++ {
+		record, err := format.row(src.Row())
+		if err != nil {
+			return fmt.Errorf("row %d: %w", rowNum, err)
 		}
 		if err := csvWriter.Write(record); err != nil {
 			return fmt.Errorf("failed to write row: %w", err)
 		}
 	}
+	if err := src.Err(); err != nil {
+		return fmt.Errorf("failed to read rows: %w", err)
+	}
 
-	return nil
+	if err := csvWriter.Flush(); err != nil {
+		return err
+	}
+	w.lossy = csvWriter.LossyCells()
+	return file.Close()
 }
 
-// WriteParallel writes rows using parallel worker pool
-func (w *Writer) WriteParallel(headers []string, rows []types.Row) error {
+// WriteParallel formats chunks of rows on parallel workers and writes them
+// in order, holding at most one chunk per worker in memory. The output file
+// is removed if writing fails or ctx is cancelled.
+func (w *Writer) WriteParallel(ctx context.Context, headers []string, src types.RowSource) error {
 	chunkSize := w.config.ChunkSize
 	if chunkSize <= 0 {
 		chunkSize = 10000
@@ -70,118 +88,65 @@ func (w *Writer) WriteParallel(headers []string, rows []types.Row) error {
 		workers = 4
 	}
 
-	// Create output file
-	file, err := os.Create(w.config.OutputPath)
+	src, err := missing.Fit(src, headers, w.config.Missing)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
 	}
-	defer file.Close()
-
-	buffered := bufio.NewWriterSize(file, 128*1024)
-	defer buffered.Flush()
-
-	csvWriter := csv.NewWriter(buffered)
-	defer csvWriter.Flush()
-
-	// Write headers
-	if len(headers) > 0 {
-		if err := csvWriter.Write(headers); err != nil {
-			return fmt.Errorf("failed to write headers: %w", err)
-		}
+	format, err := newFormatter(headers, w.config.Injection, w.config.Missing, w.config.Numbers)
+	if err != nil {
+		return err
 	}
 
-	// Split rows into chunks
-	chunks := splitIntoChunks(rows, chunkSize)
-	resultChan := make(chan processedChunk, len(chunks))
-	errChan := make(chan error, workers)
-
-	// Worker pool
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, workers)
-
-	for idx, chunk := range chunks {
-		wg.Add(1)
-		go func(chunkIdx int, chunkData []types.Row) {
-			defer wg.Done()
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			processed, err := processChunk(chunkIdx, chunkData)
-			if err != nil {
-				errChan <- err
-				return
-			}
-			resultChan <- processed
-		}(idx, chunk)
+	// Create output file
+	file, err := output.Open(w.config.OutputPath, w.config.Output)
+	if err != nil {
+		return err
 	}
+	defer file.Discard()
 
-	// Wait for all workers
-	go func() {
-		wg.Wait()
-		close(resultChan)
-		close(errChan)
-	}()
-
-	// Collect results in order
-	results := make([]processedChunk, len(chunks))
-	for result := range resultChan {
-		results[result.Index] = result
+	csvWriter, err := NewRecordWriter(file, 128*1024, w.config.CSV)
+	if err != nil {
+		return err
 	}
 
-	// Check for errors
-	select {
-	case err := <-errChan:
-		if err != nil {
-			return err
-		}
-	default:
+	// Write headers
+	if err := format.writeHeaders(csvWriter, headers); err != nil {
+		return err
 	}
 
-	// Write results in order
-	for _, result := range results {
-		for _, record := range result.Records {
+	// Format chunks in parallel, write results in order
+	processChunk := func(index, offset int, rows []types.Row) ([][]string, error) {
+		return format.rows(offset, rows)
+	}
+	err = chunk.Ordered(ctx, src, chunkSize, workers, processChunk, func(records [][]string) error {
+		for _, record := range records {
 			if err := csvWriter.Write(record); err != nil {
 				return fmt.Errorf("failed to write record: %w", err)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
-
-type processedChunk struct {
-	Index   int
-	Records [][]string
-}
-
-func processChunk(index int, rows []types.Row) (processedChunk, error) {
-	records := make([][]string, len(rows))
-	for i, row := range rows {
-		record := make([]string, len(row))
-		for j, cell := range row {
-			record[j] = fmt.Sprintf("%v", cell)
-		}
-		records[i] = record
+	if err := csvWriter.Flush(); err != nil {
+		return err
 	}
-	return processedChunk{Index: index, Records: records}, nil
+	w.lossy = csvWriter.LossyCells()
+	return file.Close()
 }
 
-func splitIntoChunks(rows []types.Row, chunkSize int) [][]types.Row {
-	var chunks [][]types.Row
-	for i := 0; i < len(rows); i += chunkSize {
-		end := i + chunkSize
-		if end > len(rows) {
-			end = len(rows)
-		}
-		chunks = append(chunks, rows[i:end])
-	}
-	return chunks
+// LossyCells returns the number of cells the last write changed because
+// the charset cannot represent some of their characters
+func (w *Writer) LossyCells() int {
+	return w.lossy
 }
 
 // Write is the main entry point for writing CSV
-func (w *Writer) Write(headers []string, rows []types.Row) error {
+func (w *Writer) Write(ctx context.Context, headers []string, src types.RowSource) error {
 	if w.config.Mode == types.ModeSync {
-		return w.WriteSync(headers, rows)
+		return w.WriteSync(ctx, headers, src)
 	}
-	return w.WriteParallel(headers, rows)
+	return w.WriteParallel(ctx, headers, src)
 }
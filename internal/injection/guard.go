@@ -0,0 +1,146 @@
+// Package injection protects text cells against CSV (formula) injection
+package injection
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// triggers are the leading characters that make spreadsheet applications
+// evaluate a cell as a formula
+const triggers = "=+-@\t\r"
+
+// Guard applies the injection policies of a sheet's columns. A nil Guard
+// leaves every cell unchanged. It is read-only and safe for concurrent use.
+type Guard struct {
+	headers  []string
+	columns  []types.InjectionPolicy
+	fallback types.InjectionPolicy // policy of columns beyond the headers
+}
+
+// NewGuard returns the Guard of opts for a sheet with headers, or nil when
+// no column is protected
+func NewGuard(opts types.InjectionOptions, headers []string) (*Guard, error) {
+	if err := CheckOptions(opts); err != nil {
+		return nil, err
+	}
+
+	g := &Guard{
+		headers:  headers,
+		columns:  make([]types.InjectionPolicy, len(headers)),
+		fallback: effective(opts.Policy),
+	}
+	active := g.fallback != types.InjectionOff
+	for i, name := range headers {
+		policy, ok := opts.Columns[name]
+		if !ok {
+			policy = opts.Policy
+		}
+		g.columns[i] = effective(policy)
+		active = active || g.columns[i] != types.InjectionOff
+	}
+	if !active {
+		return nil, nil
+	}
+	return g, nil
+}
+
+// CheckOptions reports whether opts hold valid policies
+func CheckOptions(opts types.InjectionOptions) error {
+	if err := checkPolicy(opts.Policy); err != nil {
+		return err
+	}
+	for name, policy := range opts.Columns {
+		if err := checkPolicy(policy); err != nil {
+			return fmt.Errorf("column %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func checkPolicy(policy types.InjectionPolicy) error {
+	switch policy {
+	case "", types.InjectionOff, types.InjectionPrefix, types.InjectionStrip, types.InjectionReject:
+		return nil
+	default:
+		return fmt.Errorf("invalid injection policy %q: expected off, prefix, strip or reject", policy)
+	}
+}
+
+// effective returns policy with empty meaning InjectionOff
+func effective(policy types.InjectionPolicy) types.InjectionPolicy {
+	if policy == "" {
+		return types.InjectionOff
+	}
+	return policy
+}
+
+// Prefixes reports whether any column uses the prefix policy
+func (g *Guard) Prefixes() bool {
+	if g == nil {
+		return false
+	}
+	for _, policy := range g.columns {
+		if policy == types.InjectionPrefix {
+			return true
+		}
+	}
+	return g.fallback == types.InjectionPrefix
+}
+
+// Cell returns the text of a cell in column col with the column's policy
+// applied. Text that does not start a formula, or is a number, is returned
+// unchanged.
+func (g *Guard) Cell(col int, text string) (string, error) {
+	text, quote, err := g.Protect(col, text)
+	if quote {
+		return "'" + text, nil
+	}
+	return text, err
+}
+
+// Protect is Cell for writers that can mark a cell as text: text the prefix
+// policy protects is returned unchanged with quote set, and the writer marks
+// the cell instead of prefixing it
+func (g *Guard) Protect(col int, text string) (string, bool, error) {
+	if g == nil || text == "" || strings.IndexByte(triggers, text[0]) < 0 {
+		return text, false, nil
+	}
+
+	policy := g.fallback
+	if col < len(g.columns) {
+		policy = g.columns[col]
+	}
+	if policy == types.InjectionOff || isNumber(text) {
+		return text, false, nil
+	}
+
+	switch policy {
+	case types.InjectionPrefix:
+		return text, true, nil
+	case types.InjectionStrip:
+		for text != "" && strings.IndexByte(triggers, text[0]) >= 0 && !isNumber(text) {
+			text = text[1:]
+		}
+		return text, false, nil
+	default:
+		name := fmt.Sprintf("column %d", col+1)
+		if col < len(g.headers) {
+			name = fmt.Sprintf("column %q", g.headers[col])
+		}
+		return "", false, fmt.Errorf("%s: text starting with %q could be evaluated as a formula", name, text[0])
+	}
+}
+
+// isNumber reports whether text is a plain decimal number such as "-12.5"
+// or "+1e6", which spreadsheet applications read as a value
+func isNumber(text string) bool {
+	if strings.Trim(text, "0123456789+-.eE") != "" {
+		return false
+	}
+	_, err := strconv.ParseFloat(text, 64)
+	return err == nil
+}
@@ -0,0 +1,154 @@
+package injection
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+func TestCell(t *testing.T) {
+	tests := []struct {
+		policy types.InjectionPolicy
+		text   string
+		want   string
+	}{
+		{types.InjectionPrefix, "=SUM(A1:A2)", "'=SUM(A1:A2)"},
+		{types.InjectionPrefix, "+cmd", "'+cmd"},
+		{types.InjectionPrefix, "-cmd", "'-cmd"},
+		{types.InjectionPrefix, "@SUM(1)", "'@SUM(1)"},
+		{types.InjectionPrefix, "\t=1", "'\t=1"},
+		{types.InjectionPrefix, "\r=1", "'\r=1"},
+		{types.InjectionPrefix, "a=1", "a=1"},
+		{types.InjectionPrefix, "", ""},
+		{types.InjectionPrefix, "-12.5", "-12.5"},
+		{types.InjectionPrefix, "+1e6", "+1e6"},
+		{types.InjectionPrefix, "-", "'-"},
+		{types.InjectionPrefix, "-1-2", "'-1-2"},
+		{types.InjectionStrip, "=SUM(A1:A2)", "SUM(A1:A2)"},
+		{types.InjectionStrip, "=+-@cmd", "cmd"},
+		{types.InjectionStrip, "=-12.5", "-12.5"},
+		{types.InjectionStrip, "\t\r", ""},
+		{types.InjectionStrip, "-12.5", "-12.5"},
+		{types.InjectionOff, "=SUM(A1:A2)", "=SUM(A1:A2)"},
+		{"", "=SUM(A1:A2)", "=SUM(A1:A2)"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy)+"/"+tt.text, func(t *testing.T) {
+			g, err := NewGuard(types.InjectionOptions{Policy: tt.policy}, []string{"a"})
+			if err != nil {
+				t.Fatalf("NewGuard: %v", err)
+			}
+			got, err := g.Cell(0, tt.text)
+			if err != nil {
+				t.Fatalf("Cell: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Cell(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtectMarksPrefixedText(t *testing.T) {
+	g, err := NewGuard(types.InjectionOptions{Policy: types.InjectionPrefix}, []string{"a"})
+	if err != nil {
+		t.Fatalf("NewGuard: %v", err)
+	}
+	for text, quote := range map[string]bool{"=1+1": true, "@x": true, "plain": false, "-3": false} {
+		got, gotQuote, err := g.Protect(0, text)
+		if err != nil || got != text || gotQuote != quote {
+			t.Fatalf("Protect(%q) = %q, %v, %v; want the text unchanged with quote %v", text, got, gotQuote, err, quote)
+		}
+	}
+}
+
+func TestColumnPolicies(t *testing.T) {
+	opts := types.InjectionOptions{
+		Policy: types.InjectionStrip,
+		Columns: map[string]types.InjectionPolicy{
+			"comment": types.InjectionPrefix,
+			"formula": types.InjectionOff,
+		},
+	}
+	g, err := NewGuard(opts, []string{"name", "comment", "formula"})
+	if err != nil {
+		t.Fatalf("NewGuard: %v", err)
+	}
+	// Columns beyond the headers follow the default policy
+	want := []string{"x", "'=x", "=x", "x"}
+	for col, w := range want {
+		if got, err := g.Cell(col, "=x"); err != nil || got != w {
+			t.Fatalf("column %d: Cell = %q, %v; want %q", col+1, got, err, w)
+		}
+	}
+	if !g.Prefixes() {
+		t.Fatal("Prefixes = false with a prefixed column")
+	}
+}
+
+func TestReject(t *testing.T) {
+	opts := types.InjectionOptions{Columns: map[string]types.InjectionPolicy{"comment": types.InjectionReject}}
+	g, err := NewGuard(opts, []string{"name", "comment"})
+	if err != nil {
+		t.Fatalf("NewGuard: %v", err)
+	}
+	if got, err := g.Cell(0, "=x"); err != nil || got != "=x" {
+		t.Fatalf("unprotected column: Cell = %q, %v", got, err)
+	}
+	if got, err := g.Cell(1, "-5"); err != nil || got != "-5" {
+		t.Fatalf("number: Cell = %q, %v", got, err)
+	}
+	_, err = g.Cell(1, "@x")
+	if want := `column "comment": text starting with '@' could be evaluated as a formula`; err == nil || err.Error() != want {
+		t.Fatalf("Cell = %v, want %q", err, want)
+	}
+
+	g, err = NewGuard(types.InjectionOptions{Policy: types.InjectionReject}, []string{"name"})
+	if err != nil {
+		t.Fatalf("NewGuard: %v", err)
+	}
+	if _, err := g.Cell(2, "+x"); err == nil || !strings.HasPrefix(err.Error(), "column 3: ") {
+		t.Fatalf("Cell beyond the headers = %v, want an error naming column 3", err)
+	}
+}
+
+func TestUnprotectedGuardIsNil(t *testing.T) {
+	for _, opts := range []types.InjectionOptions{
+		{},
+		{Policy: types.InjectionOff},
+		{Columns: map[string]types.InjectionPolicy{"a": types.InjectionOff}},
+	} {
+		g, err := NewGuard(opts, []string{"a"})
+		if err != nil || g != nil {
+			t.Fatalf("NewGuard(%+v) = %v, %v; want nil", opts, g, err)
+		}
+		if got, err := g.Cell(0, "=x"); err != nil || got != "=x" || g.Prefixes() {
+			t.Fatalf("nil guard changed the cell: %q, %v", got, err)
+		}
+	}
+
+	// Policies of columns missing from the headers protect nothing
+	g, err := NewGuard(types.InjectionOptions{Columns: map[string]types.InjectionPolicy{"b": types.InjectionPrefix}}, []string{"a"})
+	if err != nil || g != nil {
+		t.Fatalf("guard for an absent column = %v, %v; want nil", g, err)
+	}
+}
+
+func TestCheckOptions(t *testing.T) {
+	tests := []struct {
+		opts types.InjectionOptions
+		want string
+	}{
+		{types.InjectionOptions{Policy: "escape"}, `invalid injection policy "escape"`},
+		{types.InjectionOptions{Columns: map[string]types.InjectionPolicy{"a": "quote"}}, `column "a": invalid injection policy "quote"`},
+	}
+	for _, tt := range tests {
+		if err := CheckOptions(tt.opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("CheckOptions = %v, want an error containing %q", err, tt.want)
+		}
+		if _, err := NewGuard(tt.opts, nil); err == nil {
+			t.Fatal("NewGuard accepted invalid options")
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package input
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// DefaultInferWindow is the number of leading object records sampled to infer
+// headers when Options.InferWindow is not set
+const DefaultInferWindow = 100
+
+// Options control how decoded records are mapped onto rows
+type Options struct {
+	// Headers describes positional rows when the input does not carry a
+	// header row itself (NDJSON only)
+	Headers []string
+	// Columns selects and orders the output columns by header name. Object
+	// keys and header names not listed are dropped.
+	Columns []string
+	// InferWindow is the number of leading object records whose keys are
+	// merged, in first-seen order, to infer headers when none are given.
+	// Keys first seen after the window are dropped.
+	InferWindow int
+}
+
+func (o Options) inferWindow() int {
+	if o.InferWindow <= 0 {
+		return DefaultInferWindow
+	}
+	return o.InferWindow
+}
+
+// record is one input element before it is mapped onto columns: either a
+// positional row or an object with its keys in document order
+type record struct {
+	row    types.Row
+	keys   []string
+	values map[string]interface{}
+}
+
+func (r record) isObject() bool {
+	return r.values != nil
+}
+
+// parseRecord decodes a JSON array, object or null into a record. Numbers
+// are kept as json.Number so writers can reproduce their literals.
+func parseRecord(data []byte) (record, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return record{}, fmt.Errorf("expected a JSON array or object")
+	}
+
+	switch data[0] {
+	case '[':
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var row types.Row
+		if err := dec.Decode(&row); err != nil {
+			return record{}, err
+		}
+		return record{row: row}, nil
+	case '{':
+		return parseObject(data)
+	case 'n':
+		if string(data) == "null" {
+			return record{}, nil
+		}
+	}
+	return record{}, fmt.Errorf("expected a JSON array or object")
+}
+
+// parseObject decodes an object keeping its keys in document order
+func parseObject(data []byte) (record, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil {
+		return record{}, err
+	}
+
+	rec := record{values: make(map[string]interface{})}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return record{}, err
+		}
+		key := tok.(string)
+
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return record{}, fmt.Errorf("invalid value for %q: %w", key, err)
+		}
+		if _, seen := rec.values[key]; !seen {
+			rec.keys = append(rec.keys, key)
+		}
+		rec.values[key] = value
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return record{}, err
+	}
+	return rec, nil
+}
+
+// inferHeaders returns the union of object keys across records in
+// first-seen order
+func inferHeaders(records []record) []string {
+	var headers []string
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		for _, key := range rec.keys {
+			if !seen[key] {
+				seen[key] = true
+				headers = append(headers, key)
+			}
+		}
+	}
+	return headers
+}
+
+// columnMap maps records onto the output columns
+type columnMap struct {
+	headers []string
+	index   map[string]int
+	// positions maps each output column to its index in positional rows,
+	// or is nil when positional rows pass through unchanged
+	positions []int
+	// unprojectable is set when columns were selected but positional rows
+	// have no headers to select them by
+	unprojectable bool
+}
+
+// newColumnMap builds the mapping from the source headers to the selected
+// columns. An empty selection keeps the source headers as they are.
+func newColumnMap(source, selected []string) (*columnMap, error) {
+	m := &columnMap{headers: source}
+
+	if len(selected) > 0 {
+		m.headers = selected
+
+		// Positional rows are only projected when the source headers say
+		// which position holds which column
+		if len(source) > 0 {
+			sourceIndex := make(map[string]int, len(source))
+			for i, name := range source {
+				sourceIndex[name] = i
+			}
+			m.positions = make([]int, len(selected))
+			for i, name := range selected {
+				pos, ok := sourceIndex[name]
+				if !ok {
+					return nil, fmt.Errorf("unknown column %q", name)
+				}
+				m.positions[i] = pos
+			}
+		} else {
+			m.unprojectable = true
+		}
+	}
+
+	m.index = make(map[string]int, len(m.headers))
+	for i, name := range m.headers {
+		m.index[name] = i
+	}
+	return m, nil
+}
+
+// row maps a record onto the output columns
+func (m *columnMap) row(rec record) (types.Row, error) {
+	if rec.isObject() {
+		if len(m.headers) == 0 {
+			return nil, fmt.Errorf("object rows require headers")
+		}
+		row := make(types.Row, len(m.headers))
+		for key, value := range rec.values {
+			if col, ok := m.index[key]; ok {
+				row[col] = value
+			}
+		}
+		return row, nil
+	}
+
+	if m.unprojectable {
+		return nil, fmt.Errorf("selecting columns of array rows requires headers")
+	}
+	if m.positions == nil {
+		return rec.row, nil
+	}
+	row := make(types.Row, len(m.positions))
+	for i, pos := range m.positions {
+		if pos < len(rec.row) {
+			row[i] = rec.row[pos]
+		}
+	}
+	return row, nil
+}
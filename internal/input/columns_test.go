@@ -0,0 +1,177 @@
+package input
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// rowDecoder is the part of Decoder and NDJSONDecoder these tests use
+type rowDecoder interface {
+	Headers() ([]string, error)
+	Next() (types.Row, error)
+}
+
+// decodeAll reads the headers and every row of d
+func decodeAll(d rowDecoder) ([]string, []types.Row, error) {
+	headers, err := d.Headers()
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows []types.Row
+	for {
+		row, err := d.Next()
+		if err == io.EOF {
+			return headers, rows, nil
+		}
+		if err != nil {
+			return headers, rows, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// decoders returns a JSON array and an NDJSON decoder over the same records
+func decoders(records []string, opts Options) map[string]rowDecoder {
+	return map[string]rowDecoder{
+		"json":   NewDecoder(strings.NewReader("["+strings.Join(records, ",")+"]"), opts),
+		"ndjson": NewNDJSONDecoder(strings.NewReader(strings.Join(records, "\n")), opts),
+	}
+}
+
+func TestInferHeadersFromObjects(t *testing.T) {
+	tests := []struct {
+		name     string
+		records  []string
+		opts     Options
+		headers  []string
+		wantRows []types.Row
+	}{
+		{
+			name:     "keys merged in first-seen order",
+			records:  []string{`{"b": 1, "a": 2}`, `{"c": 3, "a": 4}`},
+			headers:  []string{"b", "a", "c"},
+			wantRows: []types.Row{{json.Number("1"), json.Number("2"), nil}, {nil, json.Number("4"), json.Number("3")}},
+		},
+		{
+			name:     "key first seen after the window is dropped",
+			records:  []string{`{"a": 1}`, `{"a": 2}`, `{"a": 3, "late": true}`},
+			opts:     Options{InferWindow: 2},
+			headers:  []string{"a"},
+			wantRows: []types.Row{{json.Number("1")}, {json.Number("2")}, {json.Number("3")}},
+		},
+		{
+			name:     "key seen on the last row of the window is kept",
+			records:  []string{`{"a": 1}`, `{"a": 2, "b": 5}`, `{"a": 3, "b": 6}`},
+			opts:     Options{InferWindow: 2},
+			headers:  []string{"a", "b"},
+			wantRows: []types.Row{{json.Number("1"), nil}, {json.Number("2"), json.Number("5")}, {json.Number("3"), json.Number("6")}},
+		},
+		{
+			name:     "fewer records than the window",
+			records:  []string{`{"a": 1}`},
+			opts:     Options{InferWindow: 50},
+			headers:  []string{"a"},
+			wantRows: []types.Row{{json.Number("1")}},
+		},
+		{
+			name:     "selected columns skip inference",
+			records:  []string{`{"a": 1, "b": 2}`, `{"c": 3}`},
+			opts:     Options{Columns: []string{"c", "a"}},
+			headers:  []string{"c", "a"},
+			wantRows: []types.Row{{nil, json.Number("1")}, {json.Number("3"), nil}},
+		},
+	}
+	for _, tt := range tests {
+		for format, d := range decoders(tt.records, tt.opts) {
+			t.Run(tt.name+"/"+format, func(t *testing.T) {
+				headers, rows, err := decodeAll(d)
+				if err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if !reflect.DeepEqual(headers, tt.headers) {
+					t.Fatalf("headers = %v, want %v", headers, tt.headers)
+				}
+				if !reflect.DeepEqual(rows, tt.wantRows) {
+					t.Fatalf("rows = %v, want %v", rows, tt.wantRows)
+				}
+			})
+		}
+	}
+}
+
+func TestInferHeadersFromEmptyInput(t *testing.T) {
+	inputs := map[string]rowDecoder{
+		"empty array":    NewDecoder(strings.NewReader(`[]`), Options{}),
+		"empty rows":     NewDecoder(strings.NewReader(`{"rows": []}`), Options{}),
+		"no rows key":    NewDecoder(strings.NewReader(`{}`), Options{}),
+		"empty ndjson":   NewNDJSONDecoder(strings.NewReader(""), Options{}),
+		"blank ndjson":   NewNDJSONDecoder(strings.NewReader("\n \n"), Options{}),
+		"window of zero": NewDecoder(strings.NewReader(`[]`), Options{InferWindow: 0}),
+	}
+	for name, d := range inputs {
+		t.Run(name, func(t *testing.T) {
+			headers, rows, err := decodeAll(d)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(headers) != 0 || len(rows) != 0 {
+				t.Fatalf("got headers %v and %d rows, want none", headers, len(rows))
+			}
+		})
+	}
+}
+
+func TestSelectColumnsOfArrayRows(t *testing.T) {
+	doc := `{"headers": ["a", "b", "c"], "rows": [[1, 2, 3], [4]]}`
+	headers, rows, err := decodeAll(NewDecoder(strings.NewReader(doc), Options{Columns: []string{"c", "a"}}))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if want := []string{"c", "a"}; !reflect.DeepEqual(headers, want) {
+		t.Fatalf("headers = %v, want %v", headers, want)
+	}
+	want := []types.Row{{json.Number("3"), json.Number("1")}, {nil, json.Number("4")}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestColumnErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		d    rowDecoder
+		want string
+	}{
+		{
+			name: "unknown column",
+			d:    NewDecoder(strings.NewReader(`{"headers": ["a"], "rows": [[1]]}`), Options{Columns: []string{"b"}}),
+			want: `unknown column "b"`,
+		},
+		{
+			name: "array rows without headers",
+			d:    NewDecoder(strings.NewReader(`[[1, 2]]`), Options{Columns: []string{"a"}}),
+			want: "selecting columns of array rows requires headers",
+		},
+		{
+			name: "object after the window without headers",
+			d:    NewDecoder(strings.NewReader(`[[1], {"a": 1}]`), Options{InferWindow: 1}),
+			want: "object rows require headers",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := decodeAll(tt.d)
+			if err == nil {
+				t.Fatal("decode succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package input
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// errSheetsDocument is returned when rows are read directly from a
+// multi-sheet document
+var errSheetsDocument = errors.New("multi-sheet input documents can only be exported to xlsx")
+
+// Decoder incrementally reads either a {"headers": [...], "rows": [...]}
+// document or a top-level array of rows. Rows may be positional arrays or
+// objects keyed by header; headers missing from the input are inferred from
+// the leading objects. Multi-sheet {"sheets": [...]} documents are
+// recognised by Headers and read through a SheetDecoder. Only the current
+// row (plus the inference window) is held in memory, so arbitrarily large
+// inputs can be exported with memory bounded by the writers' chunk size. It
+// implements types.RowDecoder and is consumed through
+// types.NewDecoderSource.
+type Decoder struct {
+	dec        *json.Decoder
+	opts       Options
+	headers    []string
+	columns    *columnMap
+	buffered   []record
+	name       string
+	headerRead bool
+	document   bool
+	sheets     bool
+	done       bool
+	count      int
+}
+
+// NewDecoder creates a decoder reading from r
+func NewDecoder(r io.Reader, opts Options) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r), opts: opts}
+}
+
+// Headers reads the input up to the first row and returns the header row.
+// In a document, the "headers" key must precede "rows".
+func (d *Decoder) Headers() ([]string, error) {
+	if d.headerRead {
+		return d.headers, nil
+	}
+	d.headerRead = true
+
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid input document: %w", err)
+	}
+
+	var source []string
+	switch tok {
+	case json.Delim('{'):
+		d.document = true
+		source, err = d.readDocumentHeaders()
+		if err != nil {
+			return nil, err
+		}
+		if d.sheets {
+			return nil, nil
+		}
+	case json.Delim('['):
+	default:
+		return nil, fmt.Errorf("invalid input document: expected an object or array, got %v", tok)
+	}
+
+	if !d.done && len(source) == 0 && len(d.opts.Columns) == 0 {
+		if source, err = d.sample(); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.columns, err = newColumnMap(source, d.opts.Columns); err != nil {
+		return nil, err
+	}
+	d.headers = d.columns.headers
+	return d.headers, nil
+}
+
+// Sheets reports whether Headers found a multi-sheet document
+func (d *Decoder) Sheets() bool {
+	return d.sheets
+}
+
+// readDocumentHeaders reads document keys until the start of the rows array,
+// or of the sheets array of a multi-sheet document
+func (d *Decoder) readDocumentHeaders() ([]string, error) {
+	var headers []string
+	for d.dec.More() {
+		key, err := d.readKey()
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "name":
+			if err := d.dec.Decode(&d.name); err != nil {
+				return nil, fmt.Errorf("invalid name: %w", err)
+			}
+		case "headers":
+			if err := d.dec.Decode(&headers); err != nil {
+				return nil, fmt.Errorf("invalid headers: %w", err)
+			}
+		case "sheets":
+			if err := d.expectDelim('['); err != nil {
+				return nil, fmt.Errorf("invalid sheets: %w", err)
+			}
+			d.sheets = true
+			return nil, nil
+		case "rows":
+			if err := d.expectDelim('['); err != nil {
+				return nil, fmt.Errorf("invalid rows: %w", err)
+			}
+			return headers, nil
+		default:
+			var skip json.RawMessage
+			if err := d.dec.Decode(&skip); err != nil {
+				return nil, fmt.Errorf("invalid value for %q: %w", key, err)
+			}
+		}
+	}
+
+	// Document without rows
+	if err := d.expectDelim('}'); err != nil {
+		return nil, err
+	}
+	d.done = true
+	return headers, nil
+}
+
+// sample buffers up to the inference window of leading records and infers
+// the headers from the objects among them
+func (d *Decoder) sample() ([]string, error) {
+	window := d.opts.inferWindow()
+	for len(d.buffered) < window && d.dec.More() {
+		rec, err := d.readRecord(len(d.buffered) + 1)
+		if err != nil {
+			return nil, err
+		}
+		d.buffered = append(d.buffered, rec)
+	}
+	return inferHeaders(d.buffered), nil
+}
+
+// Next returns the next row, or io.EOF once the rows are exhausted
+func (d *Decoder) Next() (types.Row, error) {
+	if !d.headerRead {
+		if _, err := d.Headers(); err != nil {
+			return nil, err
+		}
+	}
+	if d.sheets {
+		return nil, errSheetsDocument
+	}
+
+	var rec record
+	switch {
+	case len(d.buffered) > 0:
+		rec = d.buffered[0]
+		d.buffered = d.buffered[1:]
+	case d.done:
+		return nil, io.EOF
+	case !d.dec.More():
+		if err := d.finish(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	default:
+		var err error
+		if rec, err = d.readRecord(d.count + 1); err != nil {
+			return nil, err
+		}
+	}
+
+	row, err := d.columns.row(rec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid row %d: %w", d.count+1, err)
+	}
+	d.count++
+	return row, nil
+}
+
+// Count returns the number of rows decoded so far
+func (d *Decoder) Count() int {
+	return d.count
+}
+
+func (d *Decoder) readRecord(rowNum int) (record, error) {
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return record{}, fmt.Errorf("invalid row %d: %w", rowNum, err)
+	}
+	rec, err := parseRecord(raw)
+	if err != nil {
+		return record{}, fmt.Errorf("invalid row %d: %w", rowNum, err)
+	}
+	return rec, nil
+}
+
+// finish consumes the end of the rows array and the rest of the document
+func (d *Decoder) finish() error {
+	d.done = true
+
+	if err := d.expectDelim(']'); err != nil {
+		return err
+	}
+	if !d.document {
+		return nil
+	}
+
+	for d.dec.More() {
+		key, err := d.readKey()
+		if err != nil {
+			return err
+		}
+		if key == "headers" {
+			return fmt.Errorf("headers must appear before rows in the input document")
+		}
+		var skip json.RawMessage
+		if err := d.dec.Decode(&skip); err != nil {
+			return fmt.Errorf("invalid value for %q: %w", key, err)
+		}
+	}
+
+	return d.expectDelim('}')
+}
+
+func (d *Decoder) readKey() (string, error) {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return "", fmt.Errorf("invalid input document: %w", err)
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid input document: unexpected %v", tok)
+	}
+	return key, nil
+}
+
+func (d *Decoder) expectDelim(want json.Delim) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return fmt.Errorf("invalid input document: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("invalid input document: expected %q, got %v", want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package input
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// readAll decodes every row of doc
+func readAll(t *testing.T, doc string) ([]string, []types.Row, error) {
+	t.Helper()
+	d := NewDecoder(strings.NewReader(doc), Options{})
+	headers, err := d.Headers()
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows []types.Row
+	for {
+		row, err := d.Next()
+		if err == io.EOF {
+			return headers, rows, nil
+		}
+		if err != nil {
+			return headers, rows, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestDecoderReadsHeadersAndRows(t *testing.T) {
+	doc := `{"title": {"nested": [1, 2]}, "headers": ["a", "b"], "rows": [[1, "x"], [2, null]], "footer": "ignored"}`
+
+	headers, rows, err := readAll(t, doc)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(headers, want) {
+		t.Fatalf("headers = %v, want %v", headers, want)
+	}
+	want := []types.Row{{json.Number("1"), "x"}, {json.Number("2"), nil}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestDecoderWithoutRows(t *testing.T) {
+	for _, doc := range []string{`{"headers": ["a"]}`, `{"headers": ["a"], "rows": []}`} {
+		headers, rows, err := readAll(t, doc)
+		if err != nil {
+			t.Fatalf("%s: %v", doc, err)
+		}
+		if len(headers) != 1 || len(rows) != 0 {
+			t.Fatalf("%s: got headers %v and %d rows", doc, headers, len(rows))
+		}
+	}
+}
+
+func TestDecoderKeepsNumberLiterals(t *testing.T) {
+	doc := `{"headers": ["a", "b", "c"], "rows": [[1.50, 1e6, 12345678901234567890], [-0.0, 2E-3, 7]]}`
+
+	_, rows, err := readAll(t, doc)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []types.Row{
+		{json.Number("1.50"), json.Number("1e6"), json.Number("12345678901234567890")},
+		{json.Number("-0.0"), json.Number("2E-3"), json.Number("7")},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows = %#v, want %#v", rows, want)
+	}
+}
+
+func TestDecoderCountsRows(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`{"headers": ["a"], "rows": [[1], [2], [3]]}`), Options{})
+	src := types.NewDecoderSource(d)
+	n := 0
+	for src.Next() {
+		n++
+	}
+	if err := src.Err(); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if n != 3 || d.Count() != 3 {
+		t.Fatalf("read %d rows, counted %d, want 3", n, d.Count())
+	}
+}
+
+func TestDecoderRejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"empty", ``, "invalid input document"},
+		{"scalar document", `"rows"`, "expected an object or array"},
+		{"headers not strings", `{"headers": [1], "rows": []}`, "invalid headers"},
+		{"rows not an array", `{"headers": ["a"], "rows": {}}`, "invalid rows"},
+		{"scalar row", `{"headers": ["a"], "rows": [[1], 5]}`, "invalid row 2"},
+		{"truncated rows", `{"headers": ["a"], "rows": [[1], [2`, "invalid row 2"},
+		{"unterminated document", `{"headers": ["a"], "rows": [[1]]`, "invalid input document"},
+		{"headers after rows", `{"rows": [[1]], "headers": ["a"]}`, "headers must appear before rows"},
+		{"bad value", `{"title": tru, "headers": ["a"], "rows": []}`, `invalid value for "title"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readAll(t, tt.doc)
+			if err == nil {
+				t.Fatal("decode succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// NDJSONDecoder reads newline-delimited JSON where every line holds one row,
+// either as a positional array or as an object keyed by header. Unless
+// Options.Headers supplies them, the headers come from a header array on the
+// first line or are inferred from the leading object lines.
+type NDJSONDecoder struct {
+	reader     *bufio.Reader
+	opts       Options
+	headers    []string
+	columns    *columnMap
+	buffered   []ndjsonLine
+	headerRead bool
+	line       int
+	count      int
+}
+
+// ndjsonLine is a decoded line held back while headers are inferred
+type ndjsonLine struct {
+	num int
+	rec record
+}
+
+// NewNDJSONDecoder creates a decoder reading from r
+func NewNDJSONDecoder(r io.Reader, opts Options) *NDJSONDecoder {
+	return &NDJSONDecoder{
+		reader: bufio.NewReaderSize(r, 64*1024),
+		opts:   opts,
+	}
+}
+
+// Headers returns the header row, reading or inferring it from the leading
+// lines if needed
+func (d *NDJSONDecoder) Headers() ([]string, error) {
+	if d.headerRead {
+		return d.headers, nil
+	}
+	d.headerRead = true
+
+	source := d.opts.Headers
+	if len(source) == 0 {
+		var err error
+		if source, err = d.readHeaders(); err != nil {
+			return nil, err
+		}
+	}
+
+	columns, err := newColumnMap(source, d.opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+	d.columns = columns
+	d.headers = columns.headers
+	return d.headers, nil
+}
+
+// readHeaders reads the header array from the first line or, when the first
+// line is an object, infers the headers from the leading object lines
+func (d *NDJSONDecoder) readHeaders() ([]string, error) {
+	line, err := d.readLine()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if line[0] != '{' {
+		var headers []string
+		if err := json.Unmarshal(line, &headers); err != nil {
+			return nil, fmt.Errorf("line %d: invalid header array: %w", d.line, err)
+		}
+		return headers, nil
+	}
+
+	if err := d.bufferLine(line); err != nil {
+		return nil, err
+	}
+	// An explicit column selection makes inference unnecessary
+	if len(d.opts.Columns) > 0 {
+		return nil, nil
+	}
+
+	window := d.opts.inferWindow()
+	for len(d.buffered) < window {
+		line, err := d.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := d.bufferLine(line); err != nil {
+			return nil, err
+		}
+	}
+
+	records := make([]record, len(d.buffered))
+	for i, buffered := range d.buffered {
+		records[i] = buffered.rec
+	}
+	return inferHeaders(records), nil
+}
+
+func (d *NDJSONDecoder) bufferLine(line []byte) error {
+	rec, err := parseRecord(line)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid row: %w", d.line, err)
+	}
+	d.buffered = append(d.buffered, ndjsonLine{num: d.line, rec: rec})
+	return nil
+}
+
+// Next returns the next row, or io.EOF at the end of the input
+func (d *NDJSONDecoder) Next() (types.Row, error) {
+	if !d.headerRead {
+		if _, err := d.Headers(); err != nil {
+			return nil, err
+		}
+	}
+
+	var current ndjsonLine
+	if len(d.buffered) > 0 {
+		current = d.buffered[0]
+		d.buffered = d.buffered[1:]
+	} else {
+		line, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+		rec, err := parseRecord(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid row: %w", d.line, err)
+		}
+		current = ndjsonLine{num: d.line, rec: rec}
+	}
+
+	row, err := d.columns.row(current.rec)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", current.num, err)
+	}
+	d.count++
+	return row, nil
+}
+
+// Count returns the number of rows decoded so far
+func (d *NDJSONDecoder) Count() int {
+	return d.count
+}
+
+// readLine returns the next non-blank line, or io.EOF at the end of the input
+func (d *NDJSONDecoder) readLine() ([]byte, error) {
+	for {
+		line, err := d.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			d.line++
+			if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+				return trimmed, nil
+			}
+		}
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read line %d: %w", d.line+1, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package input
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// readNDJSON decodes every row of input
+func readNDJSON(input string, headers []string) ([]string, []types.Row, error) {
+	d := NewNDJSONDecoder(strings.NewReader(input), Options{Headers: headers})
+	got, err := d.Headers()
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows []types.Row
+	for {
+		row, err := d.Next()
+		if err == io.EOF {
+			return got, rows, nil
+		}
+		if err != nil {
+			return got, rows, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestNDJSONDecoderReadsRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		headers []string
+		want    []types.Row
+	}{
+		{
+			name:  "array rows",
+			input: "[\"a\",\"b\"]\n[1,\"x\"]\n[2,\"y\"]\n",
+			want:  []types.Row{{json.Number("1"), "x"}, {json.Number("2"), "y"}},
+		},
+		{
+			name:  "object rows by header",
+			input: "[\"a\",\"b\"]\n{\"b\":\"x\",\"a\":1,\"extra\":true}\n{\"a\":2}\n",
+			want:  []types.Row{{json.Number("1"), "x"}, {json.Number("2"), nil}},
+		},
+		{
+			name:    "supplied headers",
+			input:   "{\"a\":1,\"b\":\"x\"}\n",
+			headers: []string{"a", "b"},
+			want:    []types.Row{{json.Number("1"), "x"}},
+		},
+		{
+			name:  "blank lines and CRLF",
+			input: "\n[\"a\"]\r\n\r\n   \n[1]\r\n\t\n[2]",
+			want:  []types.Row{{json.Number("1")}, {json.Number("2")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers, rows, err := readNDJSON(tt.input, tt.headers)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(headers) == 0 {
+				t.Fatal("no headers")
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Fatalf("rows = %v, want %v", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestNDJSONDecoderEmptyInput(t *testing.T) {
+	for _, input := range []string{"", "\n\n  \n"} {
+		headers, rows, err := readNDJSON(input, nil)
+		if err != nil || len(headers) != 0 || len(rows) != 0 {
+			t.Fatalf("%q: headers %v, %d rows, error %v; want nothing", input, headers, len(rows), err)
+		}
+	}
+}
+
+func TestNDJSONDecoderReportsLineNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"bad header", "[1]\n", "line 1: invalid header array"},
+		{"bad row after blank lines", "[\"a\"]\n\n[1]\n\n[2,\n", "line 5: invalid row"},
+		{"scalar row", "[\"a\"]\n[1]\n42\n", "line 3: invalid row: expected a JSON array or object"},
+		{"object without headers", "[]\n{\"a\":1}\n", "line 2: object rows require headers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readNDJSON(tt.input, nil)
+			if err == nil {
+				t.Fatal("decode succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
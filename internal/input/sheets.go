@@ -0,0 +1,145 @@
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// SheetDecoder reads the sheets of a multi-sheet document:
+//
+//	{"sheets": [{"name": "Orders", "headers": [...], "rows": [...]}, ...]}
+//
+// Each sheet is decoded like a single-sheet document, so rows may be arrays
+// or objects and missing headers are inferred per sheet. Sheets are
+// streamed in document order: the rows of a sheet must be read to the end
+// before advancing to the next one. It implements types.SheetSource.
+type SheetDecoder struct {
+	top     *Decoder
+	current *Decoder
+	sheet   types.Sheet
+	index   int
+	count   int
+	done    bool
+	err     error
+}
+
+// NewSheetDecoder returns the sheets of the input read by d, whose Headers
+// must have been read. Input that is not a multi-sheet document yields a
+// single unnamed sheet with d's rows.
+func NewSheetDecoder(d *Decoder) *SheetDecoder {
+	return &SheetDecoder{top: d}
+}
+
+func (s *SheetDecoder) Next() bool {
+	if s.done {
+		return false
+	}
+
+	if !s.top.sheets {
+		if s.current != nil {
+			s.done = true
+			return false
+		}
+		s.current = s.top
+		s.sheet = types.Sheet{Headers: s.top.headers, Source: types.NewDecoderSource(s.top)}
+		return true
+	}
+
+	if s.current != nil {
+		if !s.current.done {
+			return s.fail(fmt.Errorf("%s was not read to the end", s.label()))
+		}
+		s.count += s.current.count
+		s.current = nil
+	}
+
+	if !s.top.dec.More() {
+		s.done = true
+		if err := s.finish(); err != nil {
+			s.err = err
+		}
+		return false
+	}
+
+	s.index++
+	d := &Decoder{dec: s.top.dec, opts: s.top.opts}
+	if _, err := d.Headers(); err != nil {
+		return s.fail(fmt.Errorf("sheet %d: %w", s.index, err))
+	}
+	if d.sheets {
+		return s.fail(fmt.Errorf("sheet %d: sheets cannot be nested", s.index))
+	}
+
+	s.current = d
+	s.sheet = types.Sheet{Name: d.name, Headers: d.headers, Source: types.NewDecoderSource(d)}
+	return true
+}
+
+func (s *SheetDecoder) fail(err error) bool {
+	s.err = err
+	s.done = true
+	return false
+}
+
+// finish consumes the end of the sheets array and the rest of the document
+func (s *SheetDecoder) finish() error {
+	if err := s.top.expectDelim(']'); err != nil {
+		return err
+	}
+	for s.top.dec.More() {
+		key, err := s.top.readKey()
+		if err != nil {
+			return err
+		}
+		var skip json.RawMessage
+		if err := s.top.dec.Decode(&skip); err != nil {
+			return fmt.Errorf("invalid value for %q: %w", key, err)
+		}
+	}
+	return s.top.expectDelim('}')
+}
+
+// Sheet returns the current sheet
+func (s *SheetDecoder) Sheet() types.Sheet {
+	return s.sheet
+}
+
+// Err returns the error that stopped iteration, or the error that stopped
+// reading the rows of the current sheet
+func (s *SheetDecoder) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.current != nil {
+		if err := s.sheet.Source.Err(); err != nil {
+			if !s.top.sheets {
+				return err
+			}
+			return fmt.Errorf("%s: %w", s.label(), err)
+		}
+	}
+	return nil
+}
+
+// label names the current sheet in errors
+func (s *SheetDecoder) label() string {
+	if s.sheet.Name != "" {
+		return fmt.Sprintf("sheet %q", s.sheet.Name)
+	}
+	return fmt.Sprintf("sheet %d", s.index)
+}
+
+// Count returns the number of rows decoded so far across all sheets
+func (s *SheetDecoder) Count() int {
+	if s.current != nil {
+		return s.count + s.current.count
+	}
+	return s.count
+}
+
+// Close releases nothing; the reader belongs to the caller
+func (s *SheetDecoder) Close() error {
+	return nil
+}
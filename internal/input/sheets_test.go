@@ -0,0 +1,151 @@
+package input
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// readSheets decodes the sheets of input and their rows
+func readSheets(t *testing.T, input string) ([]types.Sheet, [][]types.Row, error) {
+	t.Helper()
+	d := NewDecoder(strings.NewReader(input), Options{})
+	if _, err := d.Headers(); err != nil {
+		return nil, nil, err
+	}
+	sheets := NewSheetDecoder(d)
+	var (
+		got  []types.Sheet
+		rows [][]types.Row
+	)
+	for sheets.Next() {
+		sheet := sheets.Sheet()
+		var sheetRows []types.Row
+		for sheet.Source.Next() {
+			sheetRows = append(sheetRows, sheet.Source.Row())
+		}
+		got = append(got, sheet)
+		rows = append(rows, sheetRows)
+		// Like the builder, stop at the first sheet that fails
+		if sheet.Source.Err() != nil {
+			break
+		}
+	}
+	return got, rows, sheets.Err()
+}
+
+func TestSheetDecoderReadsSheetsInOrder(t *testing.T) {
+	input := `{"title": "ignored", "sheets": [
+		{"name": "Orders", "headers": ["id", "total"], "rows": [[1, 9.5], [2, 3]]},
+		{"rows": [{"sku": "a"}, {"sku": "b", "qty": 2}]},
+		{"name": "Empty", "headers": ["x"], "rows": []}
+	], "footer": {}}`
+
+	d := NewDecoder(strings.NewReader(input), Options{})
+	if _, err := d.Headers(); err != nil {
+		t.Fatalf("headers: %v", err)
+	}
+	if !d.Sheets() {
+		t.Fatal("Sheets() = false for a multi-sheet document")
+	}
+	if _, err := d.Next(); err == nil || !strings.Contains(err.Error(), "can only be exported to xlsx") {
+		t.Fatalf("Next on a multi-sheet document = %v, want an error", err)
+	}
+
+	sheets, rows, err := readSheets(t, input)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	wantNames := []string{"Orders", "", "Empty"}
+	wantHeaders := [][]string{{"id", "total"}, {"sku", "qty"}, {"x"}}
+	wantRows := [][]types.Row{
+		{{json.Number("1"), json.Number("9.5")}, {json.Number("2"), json.Number("3")}},
+		{{"a", nil}, {"b", json.Number("2")}},
+		nil,
+	}
+	if len(sheets) != len(wantNames) {
+		t.Fatalf("decoded %d sheets, want %d", len(sheets), len(wantNames))
+	}
+	for i, sheet := range sheets {
+		if sheet.Name != wantNames[i] || !reflect.DeepEqual(sheet.Headers, wantHeaders[i]) {
+			t.Errorf("sheet %d = %q %v, want %q %v", i+1, sheet.Name, sheet.Headers, wantNames[i], wantHeaders[i])
+		}
+		if !reflect.DeepEqual(rows[i], wantRows[i]) {
+			t.Errorf("sheet %d rows = %v, want %v", i+1, rows[i], wantRows[i])
+		}
+	}
+}
+
+func TestSheetDecoderSingleSheetInput(t *testing.T) {
+	sheets, rows, err := readSheets(t, `{"headers": ["a"], "rows": [[1], [2]]}`)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(sheets) != 1 || sheets[0].Name != "" || !reflect.DeepEqual(sheets[0].Headers, []string{"a"}) {
+		t.Fatalf("sheets = %+v, want one unnamed sheet", sheets)
+	}
+	if want := []types.Row{{json.Number("1")}, {json.Number("2")}}; !reflect.DeepEqual(rows[0], want) {
+		t.Fatalf("rows = %v, want %v", rows[0], want)
+	}
+}
+
+func TestSheetDecoderCountsRows(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`{"sheets": [{"rows": [[1], [2]]}, {"rows": [[3]]}]}`), Options{})
+	if _, err := d.Headers(); err != nil {
+		t.Fatalf("headers: %v", err)
+	}
+	sheets := NewSheetDecoder(d)
+	for sheets.Next() {
+		src := sheets.Sheet().Source
+		for src.Next() {
+		}
+	}
+	if err := sheets.Err(); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got := sheets.Count(); got != 3 {
+		t.Fatalf("Count() = %d, want 3", got)
+	}
+}
+
+func TestSheetDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"nested sheets", `{"sheets": [{"sheets": []}]}`, "sheet 1: sheets cannot be nested"},
+		{"sheet is not a document", `{"sheets": [{"rows": []}, 5]}`, "sheet 2: invalid input document"},
+		{"bad row while inferring headers", `{"sheets": [{"name": "A", "rows": [[1], 2]}]}`, "sheet 1: invalid row 2"},
+		{"bad row in named sheet", `{"sheets": [{"name": "A", "headers": ["x"], "rows": [[1], 2]}]}`, `sheet "A": invalid row 2`},
+		{"unterminated sheets", `{"sheets": [{"rows": []}`, "invalid input document"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readSheets(t, tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("decode = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSheetDecoderRequiresReadingSheetsToTheEnd(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`{"sheets": [{"name": "A", "rows": [[1], [2]]}, {"rows": []}]}`), Options{})
+	if _, err := d.Headers(); err != nil {
+		t.Fatalf("headers: %v", err)
+	}
+	sheets := NewSheetDecoder(d)
+	if !sheets.Next() || !sheets.Sheet().Source.Next() {
+		t.Fatal("first sheet has no rows")
+	}
+	if sheets.Next() {
+		t.Fatal("advanced past a sheet that was not read to the end")
+	}
+	if err := sheets.Err(); err == nil || !strings.Contains(err.Error(), `sheet "A" was not read to the end`) {
+		t.Fatalf("Err() = %v, want an error about sheet A", err)
+	}
+}
@@ -0,0 +1,90 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// executor is the part of every executor these tests use
+type executor interface {
+	ExecuteContext(ctx context.Context, job *types.ExportJob) error
+}
+
+// cancellingSource yields n rows and calls cancel at row cancelAt
+type cancellingSource struct {
+	n, cancelAt, i int
+	cancel         context.CancelFunc
+}
+
+func (s *cancellingSource) Next() bool {
+	if s.i == s.n {
+		return false
+	}
+	s.i++
+	if s.i == s.cancelAt {
+		s.cancel()
+	}
+	return true
+}
+
+func (s *cancellingSource) Row() types.Row { return types.Row{s.i, fmt.Sprintf("row %d", s.i)} }
+func (s *cancellingSource) Err() error     { return nil }
+func (s *cancellingSource) Close() error   { return nil }
+
+func TestCancelledExportRemovesOutput(t *testing.T) {
+	executors := map[types.ExportMode]executor{
+		types.ModeSync:       NewSyncExecutor(),
+		types.ModeParallel:   NewParallelExecutor(),
+		types.ModeGlobalPool: NewPoolExecutor(2),
+	}
+
+	for mode, exec := range executors {
+		for _, format := range []types.ExportFormat{types.FormatCSV, types.FormatXLSX} {
+			t.Run(fmt.Sprintf("%s/%s", mode, format), func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				path := filepath.Join(t.TempDir(), "out."+string(format))
+				job := &types.ExportJob{
+					Config: &types.ExportConfig{
+						Mode:       mode,
+						Format:     format,
+						Workers:    2,
+						ChunkSize:  100,
+						OutputPath: path,
+					},
+					Headers: []string{"ID", "Name"},
+					Source:  &cancellingSource{n: 10000, cancelAt: 1500, cancel: cancel},
+				}
+
+				if err := exec.ExecuteContext(ctx, job); !errors.Is(err, context.Canceled) {
+					t.Fatalf("ExecuteContext = %v, want context.Canceled", err)
+				}
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Fatalf("output file left behind after cancellation: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestCompletedExportKeepsOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	job := &types.ExportJob{
+		Config:  &types.ExportConfig{Mode: types.ModeSync, Format: types.FormatCSV, ChunkSize: 100, OutputPath: path},
+		Headers: []string{"ID", "Name"},
+		Source:  &cancellingSource{n: 10, cancel: func() {}},
+	}
+	if err := NewSyncExecutor().ExecuteContext(context.Background(), job); err != nil {
+		t.Fatalf("ExecuteContext = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+		t.Fatalf("output file missing or empty: %v", err)
+	}
+}
@@ -1,10 +1,9 @@
 package job
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/turbo-export-engine/internal/csv"
-	"github.com/turbo-export-engine/internal/xlsx"
 	"github.com/turbo-export-engine/pkg/types"
 )
 
@@ -18,16 +17,19 @@ func NewParallelExecutor() *ParallelExecutor {
 
 // Execute runs the export job with parallel workers
 func (e *ParallelExecutor) Execute(job *types.ExportJob) error {
+	return e.ExecuteContext(context.Background(), job)
+}
+
+// ExecuteContext runs the export job with parallel workers until ctx is done
+func (e *ParallelExecutor) ExecuteContext(ctx context.Context, job *types.ExportJob) error {
 	// Ensure parallel mode is set
 	job.Config.Mode = types.ModeParallel
 
 	switch job.Config.Format {
 	case types.FormatCSV:
-		writer := csv.NewWriter(job.Config)
-		return writer.WriteParallel(job.Headers, job.Rows)
+		return writeCSV(ctx, job, true)
 	case types.FormatXLSX:
-		builder := xlsx.NewBuilder(job.Config)
-		return builder.Build(job.Headers, job.Rows)
+		return buildXLSX(ctx, job)
 	default:
 		return fmt.Errorf("unsupported format: %s", job.Config.Format)
 	}
@@ -35,5 +37,5 @@ func (e *ParallelExecutor) Execute(job *types.ExportJob) error {
 
 // Process implements the JobProcessor interface
 func (e *ParallelExecutor) Process(job *types.ExportJob) error {
-	return e.Execute(job)
+	return e.ExecuteContext(jobContext(job), job)
 }
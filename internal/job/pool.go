@@ -1,12 +1,11 @@
 package job
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
-	"github.com/turbo-export-engine/internal/csv"
 	"github.com/turbo-export-engine/internal/worker"
-	"github.com/turbo-export-engine/internal/xlsx"
 	"github.com/turbo-export-engine/pkg/types"
 )
 
@@ -16,31 +15,49 @@ type PoolExecutor struct {
 	once  sync.Once
 }
 
-var globalPoolExecutor *PoolExecutor
+var (
+	globalPoolExecutor     *PoolExecutor
+	globalPoolExecutorOnce sync.Once
+)
 
-// NewPoolExecutor creates or returns the singleton pool executor
+// NewPoolExecutor creates or returns the singleton pool executor. The pool
+// size is fixed by the first call.
 func NewPoolExecutor(workers int) *PoolExecutor {
-	if globalPoolExecutor == nil {
+	globalPoolExecutorOnce.Do(func() {
 		globalPoolExecutor = &PoolExecutor{}
 		processor := &poolProcessor{}
 		globalPoolExecutor.queue = worker.GetGlobalQueue(workers, processor)
-	}
+	})
 	return globalPoolExecutor
 }
 
 // Execute submits a job to the global worker pool
 func (e *PoolExecutor) Execute(job *types.ExportJob) error {
+	return e.ExecuteContext(context.Background(), job)
+}
+
+// ExecuteContext submits a job to the global worker pool and waits for its
+// result. Submitting waits for room in the queue until ctx is done and fails
+// with worker.ErrPoolClosed once the pool has shut down. Once the job is
+// queued, cancelling ctx makes its worker stop early; ExecuteContext still
+// waits for the worker, so the job's source and output are no longer used
+// when it returns.
+func (e *PoolExecutor) ExecuteContext(ctx context.Context, job *types.ExportJob) error {
 	if job.Result == nil {
 		job.Result = make(chan error, 1)
 	}
+	job.Context = ctx
 
-	e.queue.Submit(job)
+	if err := e.queue.SubmitContext(ctx, job); err != nil {
+		return fmt.Errorf("failed to submit job %s: %w", job.ID, err)
+	}
 
-	// Wait for result
+	// The worker checks ctx before starting the job and while writing rows
 	return <-job.Result
 }
 
-// Shutdown gracefully shuts down the global pool
+// Shutdown stops the global pool after every queued job has finished.
+// Later submissions fail with worker.ErrPoolClosed.
 func (e *PoolExecutor) Shutdown() {
 	if e.queue != nil {
 		e.queue.Shutdown()
@@ -51,16 +68,16 @@ func (e *PoolExecutor) Shutdown() {
 type poolProcessor struct{}
 
 func (p *poolProcessor) Process(job *types.ExportJob) error {
+	ctx := jobContext(job)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	switch job.Config.Format {
 	case types.FormatCSV:
-		writer := csv.NewWriter(job.Config)
-		if job.Config.Mode == types.ModeSync {
-			return writer.WriteSync(job.Headers, job.Rows)
-		}
-		return writer.WriteParallel(job.Headers, job.Rows)
+		return writeCSV(ctx, job, job.Config.Mode != types.ModeSync)
 	case types.FormatXLSX:
-		builder := xlsx.NewBuilder(job.Config)
-		return builder.Build(job.Headers, job.Rows)
+		return buildXLSX(ctx, job)
 	default:
 		return fmt.Errorf("unsupported format: %s", job.Config.Format)
 	}
@@ -0,0 +1,69 @@
+package job
+
+import (
+	"context"
+	"errors"
+
+	"github.com/turbo-export-engine/internal/csv"
+	"github.com/turbo-export-engine/internal/xlsx"
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// jobSource returns the job's row source, falling back to its materialized rows
+func jobSource(job *types.ExportJob) types.RowSource {
+	if job.Source != nil {
+		return job.Source
+	}
+	return types.NewSliceSource(job.Rows)
+}
+
+// errSheetsFormat rejects multi-sheet jobs for formats without sheets
+var errSheetsFormat = errors.New("multi-sheet exports require the xlsx format")
+
+// jobSheets returns the job's sheets, falling back to a single sheet of its
+// headers and rows
+func jobSheets(job *types.ExportJob) types.SheetSource {
+	if job.Sheets != nil {
+		return job.Sheets
+	}
+	return types.NewSheetSource([]types.Sheet{{Headers: job.Headers, Source: jobSource(job)}})
+}
+
+// buildXLSX builds the job's workbook and records its sheets in the job
+func buildXLSX(ctx context.Context, job *types.ExportJob) error {
+	builder := xlsx.NewBuilder(job.Config)
+	if err := builder.BuildSheets(ctx, jobSheets(job)); err != nil {
+		return err
+	}
+	job.SheetResults = builder.Sheets()
+	return nil
+}
+
+// writeCSV writes the job's rows as CSV, formatting them on workers when
+// parallel is set, and records the cells changed to fit the charset
+func writeCSV(ctx context.Context, job *types.ExportJob, parallel bool) error {
+	if job.Sheets != nil {
+		return errSheetsFormat
+	}
+
+	writer := csv.NewWriter(job.Config)
+	var err error
+	if parallel {
+		err = writer.WriteParallel(ctx, job.Headers, jobSource(job))
+	} else {
+		err = writer.WriteSync(ctx, job.Headers, jobSource(job))
+	}
+	if err != nil {
+		return err
+	}
+	job.LossyCells = writer.LossyCells()
+	return nil
+}
+
+// jobContext returns the context carried by the job
+func jobContext(job *types.ExportJob) context.Context {
+	if job.Context != nil {
+		return job.Context
+	}
+	return context.Background()
+}
@@ -1,10 +1,9 @@
 package job
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/turbo-export-engine/internal/csv"
-	"github.com/turbo-export-engine/internal/xlsx"
 	"github.com/turbo-export-engine/pkg/types"
 )
 
@@ -18,13 +17,16 @@ func NewSyncExecutor() *SyncExecutor {
 
 // Execute runs the export job synchronously
 func (e *SyncExecutor) Execute(job *types.ExportJob) error {
+	return e.ExecuteContext(context.Background(), job)
+}
+
+// ExecuteContext runs the export job synchronously until ctx is done
+func (e *SyncExecutor) ExecuteContext(ctx context.Context, job *types.ExportJob) error {
 	switch job.Config.Format {
 	case types.FormatCSV:
-		writer := csv.NewWriter(job.Config)
-		return writer.WriteSync(job.Headers, job.Rows)
+		return writeCSV(ctx, job, false)
 	case types.FormatXLSX:
-		builder := xlsx.NewBuilder(job.Config)
-		return builder.Build(job.Headers, job.Rows)
+		return buildXLSX(ctx, job)
 	default:
 		return fmt.Errorf("unsupported format: %s", job.Config.Format)
 	}
@@ -32,5 +34,5 @@ func (e *SyncExecutor) Execute(job *types.ExportJob) error {
 
 // Process implements the JobProcessor interface
 func (e *SyncExecutor) Process(job *types.ExportJob) error {
-	return e.Execute(job)
+	return e.ExecuteContext(jobContext(job), job)
 }
@@ -0,0 +1,127 @@
+// Package missing fits rows to their headers by the ragged-row policy
+// shared by every writer
+package missing
+
+import (
+	"fmt"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// CheckOptions reports whether opts hold a valid ragged-row policy
+func CheckOptions(opts types.MissingOptions) error {
+	switch opts.Ragged {
+	case "", types.RaggedPad, types.RaggedTruncate, types.RaggedError:
+		return nil
+	default:
+		return fmt.Errorf("invalid ragged row policy %q: expected pad, truncate or error", opts.Ragged)
+	}
+}
+
+// fitter fits rows to a number of columns
+type fitter struct {
+	width  int
+	policy types.RaggedPolicy
+}
+
+// row returns row fitted to the width. rowNum is the 1-based number of the
+// row for errors.
+func (f fitter) row(row types.Row, rowNum int) (types.Row, error) {
+	switch {
+	case len(row) == f.width:
+		return row, nil
+	case f.policy == types.RaggedError:
+		return nil, fmt.Errorf("row %d: expected %d values, got %d", rowNum, f.width, len(row))
+	case len(row) < f.width:
+		padded := make(types.Row, f.width)
+		copy(padded, row)
+		return padded, nil
+	case f.policy == types.RaggedTruncate:
+		return row[:f.width:f.width], nil
+	default:
+		return row, nil
+	}
+}
+
+// Fit returns src with its rows fitted to headers by the ragged-row policy
+// of opts. Padded values are nil. Sources without headers are returned as
+// they are. Rows held in memory stay visible, already fitted, so writers
+// can size their output.
+func Fit(src types.RowSource, headers []string, opts types.MissingOptions) (types.RowSource, error) {
+	if err := CheckOptions(opts); err != nil {
+		return nil, err
+	}
+	if len(headers) == 0 {
+		return src, nil
+	}
+
+	policy := opts.Ragged
+	if policy == "" {
+		policy = types.RaggedPad
+	}
+	fitted := &fittedSource{RowSource: src, fit: fitter{width: len(headers), policy: policy}}
+	if memory, ok := src.(types.MemorySource); ok {
+		return fittedMemorySource{fittedSource: fitted, memory: memory}, nil
+	}
+	return fitted, nil
+}
+
+// fittedSource fits the rows pulled through it
+type fittedSource struct {
+	types.RowSource
+	fit    fitter
+	rowNum int
+	row    types.Row
+	err    error
+}
+
+func (s *fittedSource) Next() bool {
+	if s.err != nil || !s.RowSource.Next() {
+		return false
+	}
+	s.rowNum++
+	s.row, s.err = s.fit.row(s.RowSource.Row(), s.rowNum)
+	return s.err == nil
+}
+
+func (s *fittedSource) Row() types.Row {
+	return s.row
+}
+
+func (s *fittedSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.RowSource.Err()
+}
+
+// fittedMemorySource is a fittedSource over rows held in memory
+type fittedMemorySource struct {
+	*fittedSource
+	memory types.MemorySource
+}
+
+// Pending returns the rows not yet pulled, fitted. Rows that cannot be
+// fitted are returned as they are; pulling them fails.
+func (s fittedMemorySource) Pending() []types.Row {
+	pending := s.memory.Pending()
+	var fitted []types.Row // copy of pending, made once a row changes
+	for i, row := range pending {
+		r, err := s.fit.row(row, s.rowNum+i+1)
+		if err != nil {
+			r = row
+		}
+		if fitted == nil {
+			if len(r) == len(row) {
+				continue
+			}
+			fitted = make([]types.Row, len(pending))
+			copy(fitted, pending[:i])
+		}
+		fitted[i] = r
+	}
+	if fitted == nil {
+		return pending
+	}
+	return fitted
+}
@@ -0,0 +1,145 @@
+package missing
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// streamSource hides the MemorySource of a slice source
+type streamSource struct {
+	types.RowSource
+}
+
+// pull fits rows to headers and reads them all, returning the rows read
+// before the first error
+func pull(t *testing.T, src types.RowSource, headers []string, opts types.MissingOptions) ([]types.Row, error) {
+	t.Helper()
+	fitted, err := Fit(src, headers, opts)
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	var rows []types.Row
+	for fitted.Next() {
+		rows = append(rows, fitted.Row())
+	}
+	return rows, fitted.Err()
+}
+
+func TestFit(t *testing.T) {
+	headers := []string{"a", "b", "c"}
+	rows := []types.Row{
+		{1, 2, 3},
+		{4},
+		{5, nil, 6, 7},
+		{},
+		{nil, nil, nil},
+	}
+	tests := []struct {
+		name string
+		opts types.MissingOptions
+		want []types.Row
+		err  string
+	}{
+		{
+			name: "default pads",
+			want: []types.Row{{1, 2, 3}, {4, nil, nil}, {5, nil, 6, 7}, {nil, nil, nil}, {nil, nil, nil}},
+		},
+		{
+			name: "pad with a null token",
+			opts: types.MissingOptions{Null: `\N`, Ragged: types.RaggedPad},
+			want: []types.Row{{1, 2, 3}, {4, nil, nil}, {5, nil, 6, 7}, {nil, nil, nil}, {nil, nil, nil}},
+		},
+		{
+			name: "truncate",
+			opts: types.MissingOptions{Ragged: types.RaggedTruncate},
+			want: []types.Row{{1, 2, 3}, {4, nil, nil}, {5, nil, 6}, {nil, nil, nil}, {nil, nil, nil}},
+		},
+		{
+			name: "error on a short row",
+			opts: types.MissingOptions{Ragged: types.RaggedError},
+			want: []types.Row{{1, 2, 3}},
+			err:  "row 2: expected 3 values, got 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, src := range []types.RowSource{types.NewSliceSource(rows), streamSource{types.NewSliceSource(rows)}} {
+				got, err := pull(t, src, headers, tt.opts)
+				if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+					t.Fatalf("%T: Err = %v, want %q", src, err, tt.err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("%T: rows = %v, want %v", src, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFitErrorOnLongRow(t *testing.T) {
+	rows := []types.Row{{1, 2}, {3, 4}, {5, 6, 7}}
+	got, err := pull(t, types.NewSliceSource(rows), []string{"a", "b"}, types.MissingOptions{Ragged: types.RaggedError})
+	if want := "row 3: expected 2 values, got 3"; err == nil || err.Error() != want {
+		t.Fatalf("Err = %v, want %q", err, want)
+	}
+	if len(got) != 2 {
+		t.Fatalf("%d rows read before the ragged row, want 2", len(got))
+	}
+}
+
+func TestFitPending(t *testing.T) {
+	rows := []types.Row{{1, 2}, {3}, {4, 5, 6}}
+	src, err := Fit(types.NewSliceSource(rows), []string{"a", "b"}, types.MissingOptions{Ragged: types.RaggedTruncate})
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	memory, ok := src.(types.MemorySource)
+	if !ok {
+		t.Fatal("fitted slice source is not a MemorySource")
+	}
+	if !src.Next() {
+		t.Fatalf("Next = false: %v", src.Err())
+	}
+	want := []types.Row{{3, nil}, {4, 5}}
+	if got := memory.Pending(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Pending = %v, want %v", got, want)
+	}
+	if rows[1][0] != 3 || len(rows[1]) != 1 || len(rows[2]) != 3 {
+		t.Fatal("Pending changed the source rows")
+	}
+
+	// Rows that already fit are returned without a copy
+	src, err = Fit(types.NewSliceSource(rows[:1]), []string{"a", "b"}, types.MissingOptions{})
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if got := src.(types.MemorySource).Pending(); &got[0][0] != &rows[0][0] {
+		t.Fatal("Pending copied rows that fit")
+	}
+}
+
+func TestFitWithoutHeaders(t *testing.T) {
+	src := types.NewSliceSource([]types.Row{{1}, {2, 3}})
+	fitted, err := Fit(src, nil, types.MissingOptions{Ragged: types.RaggedError})
+	if err != nil || fitted != src {
+		t.Fatalf("Fit without headers = %v, %v; want the source", fitted, err)
+	}
+}
+
+func TestCheckOptions(t *testing.T) {
+	for _, policy := range []types.RaggedPolicy{"", types.RaggedPad, types.RaggedTruncate, types.RaggedError} {
+		if err := CheckOptions(types.MissingOptions{Ragged: policy}); err != nil {
+			t.Fatalf("CheckOptions(%q) = %v", policy, err)
+		}
+	}
+	err := CheckOptions(types.MissingOptions{Ragged: "drop"})
+	if err == nil || !strings.Contains(err.Error(), `invalid ragged row policy "drop"`) {
+		t.Fatalf("CheckOptions = %v, want an invalid policy error", err)
+	}
+	if _, err := Fit(types.NewSliceSource(nil), []string{"a"}, types.MissingOptions{Ragged: "drop"}); err == nil {
+		t.Fatal("Fit accepted an invalid policy")
+	}
+}
@@ -0,0 +1,57 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Destination is where an export writes its bytes: either a caller-supplied
+// writer or a file created for the export
+type Destination struct {
+	io.Writer
+	file   *os.File
+	closed bool
+}
+
+// Open returns the destination of an export: w when it is set, otherwise a
+// newly created file at path. The caller's writer is never closed.
+func Open(path string, w io.Writer) (*Destination, error) {
+	if w != nil {
+		return &Destination{Writer: w}, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	return &Destination{Writer: file, file: file}, nil
+}
+
+// Close completes the export and closes the output file
+func (d *Destination) Close() error {
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+
+	if d.file != nil {
+		return d.file.Close()
+	}
+	return nil
+}
+
+// Discard abandons an incomplete export by closing and removing the output
+// file. It does nothing after Close, so it can be deferred unconditionally.
+// Bytes already written to a caller-supplied writer cannot be taken back.
+func (d *Destination) Discard() {
+	if d.closed {
+		return
+	}
+	d.closed = true
+
+	if d.file != nil {
+		d.file.Close()
+		os.Remove(d.file.Name())
+	}
+}
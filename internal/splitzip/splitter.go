@@ -2,11 +2,12 @@ package splitzip
 
 import (
 	"archive/zip"
+	"context"
 	"fmt"
-	"os"
-	"sort"
-	"sync"
 
+	"github.com/turbo-export-engine/internal/chunk"
+	"github.com/turbo-export-engine/internal/missing"
+	"github.com/turbo-export-engine/internal/output"
 	"github.com/turbo-export-engine/pkg/types"
 )
 
@@ -18,158 +19,140 @@ func NewSplitter(config *types.SplitZipConfig) *Splitter {
 	return &Splitter{config: config}
 }
 
-func (s *Splitter) Execute(headers []string, rows []types.Row) (*types.SplitZipResult, error) {
+// Execute splits rows pulled from the source into parts and writes them to
+// a ZIP archive. Only the parts currently being generated are held in memory.
+func (s *Splitter) Execute(headers []string, src types.RowSource) (*types.SplitZipResult, error) {
+	return s.ExecuteContext(context.Background(), headers, src)
+}
+
+// ExecuteContext is like Execute but stops once ctx is done. The output file
+// is removed if the export fails or is cancelled.
+func (s *Splitter) ExecuteContext(ctx context.Context, headers []string, src types.RowSource) (*types.SplitZipResult, error) {
 	if !s.config.Split || !s.config.Zip {
 		return nil, fmt.Errorf("split and zip must both be enabled")
 	}
 
+	src, err := missing.Fit(chunk.WithContext(ctx, src), headers, s.config.Missing)
+	if err != nil {
+		return nil, err
+	}
+
 	chunkSize := s.config.ChunkSize
 	if chunkSize <= 0 {
 		chunkSize = 10000
 	}
 
-	totalRows := len(rows)
-	numParts := (totalRows + chunkSize - 1) / chunkSize
-	if numParts == 0 {
-		numParts = 1
-	}
-
-	file, err := os.Create(s.config.OutputPath)
+	file, err := output.Open(s.config.OutputPath, s.config.Output)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create output file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
+	defer file.Discard()
 
+	// The zip writer is only closed on success; an aborted export leaves
+	// the archive without its central directory
 	zipWriter := zip.NewWriter(file)
-	defer zipWriter.Close()
 
-	var partFiles []string
+	result := &types.SplitZipResult{
+		OutputPath: s.config.OutputPath,
+	}
 
 	switch s.config.Mode {
 	case types.ModeSync:
-		partFiles, err = s.executeSync(zipWriter, headers, rows, chunkSize, numParts)
+		err = s.executeSync(zipWriter, headers, src, chunkSize, result)
 	case types.ModeParallel, types.ModeGlobalPool:
-		partFiles, err = s.executeParallel(zipWriter, headers, rows, chunkSize, numParts)
+		err = s.executeParallel(ctx, zipWriter, headers, src, chunkSize, result)
 	default:
-		partFiles, err = s.executeSync(zipWriter, headers, rows, chunkSize, numParts)
+		err = s.executeSync(zipWriter, headers, src, chunkSize, result)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &types.SplitZipResult{
-		OutputPath: s.config.OutputPath,
-		TotalParts: numParts,
-		TotalRows:  totalRows,
-		PartFiles:  partFiles,
-	}, nil
-}
+	// An empty input still produces a single (header-only) part
+	if result.TotalParts == 0 {
+		filename := s.getPartFilename(0)
+		lossy, err := s.writePartToZip(zipWriter, filename, headers, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write part 1: %w", err)
+		}
+		result.TotalParts = 1
+		result.LossyCells += lossy
+		result.PartFiles = append(result.PartFiles, filename)
+	}
 
-func (s *Splitter) executeSync(zw *zip.Writer, headers []string, rows []types.Row, chunkSize, numParts int) ([]string, error) {
-	partFiles := make([]string, 0, numParts)
+	// Closing writes the central directory, which completes the archive
+	if err := zipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize zip: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
 
-	for partIdx := 0; partIdx < numParts; partIdx++ {
-		startIdx := partIdx * chunkSize
-		endIdx := startIdx + chunkSize
-		if endIdx > len(rows) {
-			endIdx = len(rows)
+	return result, nil
+}
+
+func (s *Splitter) executeSync(zw *zip.Writer, headers []string, src types.RowSource, chunkSize int, result *types.SplitZipResult) error {
+	for partIdx := 0; ; partIdx++ {
+		partRows, err := chunk.Collect(src, chunkSize)
+		if err != nil {
+			return fmt.Errorf("failed to read rows: %w", err)
+		}
+		if len(partRows) == 0 {
+			return nil
 		}
 
-		partRows := rows[startIdx:endIdx]
 		filename := s.getPartFilename(partIdx)
 
-		if err := s.writePartToZip(zw, filename, headers, partRows); err != nil {
-			return nil, fmt.Errorf("failed to write part %d: %w", partIdx+1, err)
+		lossy, err := s.writePartToZip(zw, filename, headers, partRows)
+		if err != nil {
+			return fmt.Errorf("failed to write part %d: %w", partIdx+1, err)
 		}
 
-		partFiles = append(partFiles, filename)
+		result.TotalParts++
+		result.TotalRows += len(partRows)
+		result.LossyCells += lossy
+		result.PartFiles = append(result.PartFiles, filename)
 	}
-
-	return partFiles, nil
 }
 
-func (s *Splitter) executeParallel(zw *zip.Writer, headers []string, rows []types.Row, chunkSize, numParts int) ([]string, error) {
+func (s *Splitter) executeParallel(ctx context.Context, zw *zip.Writer, headers []string, src types.RowSource, chunkSize int, result *types.SplitZipResult) error {
 	workers := s.config.Workers
 	if workers <= 0 {
 		workers = 4
 	}
 
-	resultChan := make(chan types.PartResult, numParts)
-	errChan := make(chan error, workers)
-
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, workers)
-
-	for partIdx := 0; partIdx < numParts; partIdx++ {
-		startIdx := partIdx * chunkSize
-		endIdx := startIdx + chunkSize
-		if endIdx > len(rows) {
-			endIdx = len(rows)
-		}
-
-		partRows := rows[startIdx:endIdx]
-
-		wg.Add(1)
-		go func(idx int, data []types.Row) {
-			defer wg.Done()
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			partData, err := s.generatePartData(headers, data)
-			if err != nil {
-				errChan <- fmt.Errorf("part %d: %w", idx+1, err)
-				return
-			}
-
-			resultChan <- types.PartResult{
-				PartIndex: idx,
-				Data:      partData,
-				RowCount:  len(data),
-			}
-		}(partIdx, partRows)
-	}
-
-	go func() {
-		wg.Wait()
-		close(resultChan)
-		close(errChan)
-	}()
-
-	results := make([]types.PartResult, 0, numParts)
-	for result := range resultChan {
-		results = append(results, result)
-	}
-
-	select {
-	case err := <-errChan:
+	process := func(idx, offset int, data []types.Row) (types.PartResult, error) {
+		partData, lossy, err := s.generatePartData(headers, data)
 		if err != nil {
-			return nil, err
+			return types.PartResult{}, fmt.Errorf("part %d: %w", idx+1, err)
 		}
-	default:
+		return types.PartResult{
+			PartIndex:  idx,
+			Data:       partData,
+			RowCount:   len(data),
+			LossyCells: lossy,
+		}, nil
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].PartIndex < results[j].PartIndex
-	})
-
-	partFiles := make([]string, 0, numParts)
-	for _, result := range results {
-		filename := s.getPartFilename(result.PartIndex)
+	return chunk.Ordered(ctx, src, chunkSize, workers, process, func(part types.PartResult) error {
+		filename := s.getPartFilename(part.PartIndex)
 
 		w, err := zw.Create(filename)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create zip entry %s: %w", filename, err)
+			return fmt.Errorf("failed to create zip entry %s: %w", filename, err)
 		}
 
-		if _, err := w.Write(result.Data); err != nil {
-			return nil, fmt.Errorf("failed to write zip entry %s: %w", filename, err)
+		if _, err := w.Write(part.Data); err != nil {
+			return fmt.Errorf("failed to write zip entry %s: %w", filename, err)
 		}
 
-		partFiles = append(partFiles, filename)
-	}
-
-	return partFiles, nil
+		result.TotalParts++
+		result.TotalRows += part.RowCount
+		result.LossyCells += part.LossyCells
+		result.PartFiles = append(result.PartFiles, filename)
+		return nil
+	})
 }
 
 func (s *Splitter) getPartFilename(partIdx int) string {
@@ -180,24 +163,29 @@ func (s *Splitter) getPartFilename(partIdx int) string {
 	return fmt.Sprintf("part_%d.%s", partIdx+1, ext)
 }
 
-func (s *Splitter) writePartToZip(zw *zip.Writer, filename string, headers []string, rows []types.Row) error {
+// writePartToZip writes a part and returns the number of its cells changed
+// to fit the CSV charset
+func (s *Splitter) writePartToZip(zw *zip.Writer, filename string, headers []string, rows []types.Row) (int, error) {
 	switch s.config.Format {
 	case types.FormatCSV:
-		return writeCSVPartToZip(zw, filename, headers, rows, s.config.IncludeHeaders)
+		return writeCSVPartToZip(zw, filename, headers, rows, s.config)
 	case types.FormatXLSX:
-		return writeXLSXPartToZip(zw, filename, headers, rows, s.config.IncludeHeaders)
+		return 0, writeXLSXPartToZip(zw, filename, headers, rows, s.config)
 	default:
-		return fmt.Errorf("unsupported format: %s", s.config.Format)
+		return 0, fmt.Errorf("unsupported format: %s", s.config.Format)
 	}
 }
 
-func (s *Splitter) generatePartData(headers []string, rows []types.Row) ([]byte, error) {
+// generatePartData renders a part and returns the number of its cells
+// changed to fit the CSV charset
+func (s *Splitter) generatePartData(headers []string, rows []types.Row) ([]byte, int, error) {
 	switch s.config.Format {
 	case types.FormatCSV:
-		return generateCSVPartData(headers, rows, s.config.IncludeHeaders)
+		return generateCSVPartData(headers, rows, s.config)
 	case types.FormatXLSX:
-		return generateXLSXPartData(headers, rows, s.config.IncludeHeaders)
+		data, err := generateXLSXPartData(headers, rows, s.config)
+		return data, 0, err
 	default:
-		return nil, fmt.Errorf("unsupported format: %s", s.config.Format)
+		return nil, 0, fmt.Errorf("unsupported format: %s", s.config.Format)
 	}
 }
@@ -0,0 +1,121 @@
+// Package values formats cell values as text the same way in every writer
+package values
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// maxPrecision bounds the decimals of floats, beyond which float64 holds
+// no further digits
+const maxPrecision = 20
+
+// CheckOptions reports whether opts hold a valid float precision
+func CheckOptions(opts types.NumberOptions) error {
+	if p := opts.FloatPrecision; p != nil && (*p < 0 || *p > maxPrecision) {
+		return fmt.Errorf("invalid float precision %d: expected 0 to %d", *p, maxPrecision)
+	}
+	return nil
+}
+
+// Formatter formats cell values as text. Formatters are made by
+// NewFormatter; they are read-only and safe for concurrent use.
+type Formatter struct {
+	precision int // decimals of floats, -1 for the fewest exact digits
+}
+
+// NewFormatter returns the Formatter of opts
+func NewFormatter(opts types.NumberOptions) (Formatter, error) {
+	if err := CheckOptions(opts); err != nil {
+		return Formatter{}, err
+	}
+	if opts.FloatPrecision == nil {
+		return Formatter{precision: -1}, nil
+	}
+	return Formatter{precision: *opts.FloatPrecision}, nil
+}
+
+// Text returns the text of a non-nil cell value
+func (f Formatter) Text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return f.number(v)
+	case float64:
+		return f.float(v, 64)
+	case float32:
+		return f.float(float64(v), 32)
+	case int:
+		return strconv.FormatInt(int64(v), 10)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// number formats a JSON number literal. Integers and, without a precision,
+// plain decimals are kept as they are.
+func (f Formatter) number(n json.Number) string {
+	literal := string(n)
+	if decimalChars(literal) && (f.precision < 0 || !strings.Contains(literal, ".")) {
+		return literal
+	}
+	v, err := n.Float64()
+	if err != nil {
+		return literal
+	}
+	return f.float(v, 64)
+}
+
+// float formats a float without exponent
+func (f Formatter) float(v float64, bitSize int) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'g', -1, bitSize)
+	}
+	return strconv.FormatFloat(v, 'f', f.precision, bitSize)
+}
+
+// IsPlainDecimal reports whether a number literal is written in plain
+// decimal notation, without exponent
+func IsPlainDecimal(literal string) bool {
+	if !decimalChars(literal) {
+		return false
+	}
+	_, err := strconv.ParseFloat(literal, 64)
+	return err == nil || errors.Is(err, strconv.ErrRange)
+}
+
+// decimalChars reports whether literal holds only digits, signs and points
+func decimalChars(literal string) bool {
+	for i := 0; i < len(literal); i++ {
+		if c := literal[i]; (c < '0' || c > '9') && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return literal != ""
+}
@@ -0,0 +1,101 @@
+package values
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// precision returns a pointer to p for NumberOptions
+func precision(p int) *int {
+	return &p
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision *int
+		value     interface{}
+		want      string
+	}{
+		{"nil float", nil, 0.5, "0.5"},
+		{"nil shortest float", nil, 1.0 / 3, "0.3333333333333333"},
+		{"nil large float", nil, 1e21, "1000000000000000000000"},
+		{"nil small float", nil, 1e-7, "0.0000001"},
+		{"nil float32", nil, float32(0.1), "0.1"},
+		{"nil literal", nil, json.Number("1.50"), "1.50"},
+		{"nil exponent literal", nil, json.Number("1e6"), "1000000"},
+		{"nil negative exponent literal", nil, json.Number("-2.5E-3"), "-0.0025"},
+		{"nil big integer literal", nil, json.Number("12345678901234567890"), "12345678901234567890"},
+		{"0 float", precision(0), 2.5, "2"},
+		{"0 rounds up", precision(0), 2.51, "3"},
+		{"0 literal", precision(0), json.Number("1.50"), "2"},
+		{"0 exponent literal", precision(0), json.Number("1e6"), "1000000"},
+		{"0 integer literal", precision(0), json.Number("7"), "7"},
+		{"2 float", precision(2), 2.0 / 3, "0.67"},
+		{"2 float32", precision(2), float32(0.1), "0.10"},
+		{"2 literal", precision(2), json.Number("1.50"), "1.50"},
+		{"2 long literal", precision(2), json.Number("3.14159"), "3.14"},
+		{"2 exponent literal", precision(2), json.Number("1e6"), "1000000.00"},
+		{"2 integer literal", precision(2), json.Number("42"), "42"},
+		{"2 integer", precision(2), 42, "42"},
+		{"20 float", precision(20), 0.1, "0.10000000000000000555"},
+		{"20 literal", precision(20), json.Number("1.50"), "1.50000000000000000000"},
+		{"20 exponent literal", precision(20), json.Number("1e6"), "1000000.00000000000000000000"},
+		{"int64", nil, int64(math.MinInt64), "-9223372036854775808"},
+		{"uint64", nil, uint64(math.MaxUint64), "18446744073709551615"},
+		{"int8", precision(2), int8(-5), "-5"},
+		{"bool", nil, true, "true"},
+		{"string", precision(2), "1.005", "1.005"},
+		{"NaN", precision(2), math.NaN(), "NaN"},
+		{"infinity", nil, math.Inf(-1), "-Inf"},
+		{"invalid literal", precision(2), json.Number("abc"), "abc"},
+		{"other", nil, []int{1, 2}, "[1 2]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFormatter(types.NumberOptions{FloatPrecision: tt.precision})
+			if err != nil {
+				t.Fatalf("NewFormatter: %v", err)
+			}
+			if got := f.Text(tt.value); got != tt.want {
+				t.Fatalf("Text(%v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidPrecision(t *testing.T) {
+	for _, p := range []int{-1, 21, math.MaxInt32} {
+		opts := types.NumberOptions{FloatPrecision: precision(p)}
+		err := CheckOptions(opts)
+		if err == nil || !strings.Contains(err.Error(), "invalid float precision") {
+			t.Fatalf("CheckOptions(%d) = %v, want an invalid precision error", p, err)
+		}
+		if _, err := NewFormatter(opts); err == nil {
+			t.Fatalf("NewFormatter accepted precision %d", p)
+		}
+	}
+}
+
+func TestIsPlainDecimal(t *testing.T) {
+	tests := map[string]bool{
+		"1":                            true,
+		"-1.50":                        true,
+		"0.0":                          true,
+		"1e6":                          false,
+		"2E-3":                         false,
+		"1-2":                          false,
+		"":                             false,
+		"-":                            false,
+		"1" + strings.Repeat("0", 400): true,
+	}
+	for literal, want := range tests {
+		if got := IsPlainDecimal(literal); got != want {
+			t.Fatalf("IsPlainDecimal(%q) = %v, want %v", literal, got, want)
+		}
+	}
+}
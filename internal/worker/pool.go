@@ -1,20 +1,41 @@
 package worker
 
 import (
+	"context"
+	"errors"
+	"runtime/debug"
 	"sync"
 
 	"github.com/turbo-export-engine/pkg/types"
 )
 
-// Pool represents a worker pool for processing export jobs
+var (
+	// ErrPoolClosed is returned when submitting to a pool that is shutting
+	// down or has shut down
+	ErrPoolClosed = errors.New("worker pool is closed")
+	// ErrQueueFull is returned by Submit when the job queue has no room
+	ErrQueueFull = errors.New("worker queue is full")
+)
+
+// Pool represents a worker pool for processing export jobs. Every job it
+// accepts is processed and, if the job has a Result channel, receives
+// exactly one result, even when the pool is shut down meanwhile.
 type Pool struct {
 	workers   int
 	jobQueue  chan *types.ExportJob
 	wg        sync.WaitGroup
 	processor JobProcessor
 	once      sync.Once
-	stopped   bool
-	mu        sync.Mutex
+
+	// mu is held for reading while a job is being enqueued and for writing
+	// while the queue is closed, so no send can race with the close
+	mu     sync.RWMutex
+	closed bool
+
+	// quit is closed when shutdown begins, releasing blocked submitters
+	// so that Shutdown can take mu
+	quit     chan struct{}
+	quitOnce sync.Once
 }
 
 // JobProcessor defines the interface for processing jobs
@@ -35,7 +56,7 @@ func NewPool(workers int, queueSize int, processor JobProcessor) *Pool {
 		workers:   workers,
 		jobQueue:  make(chan *types.ExportJob, queueSize),
 		processor: processor,
-		stopped:   false,
+		quit:      make(chan struct{}),
 	}
 }
 
@@ -49,12 +70,13 @@ func (p *Pool) Start() {
 	})
 }
 
-// worker is the main worker goroutine
+// worker is the main worker goroutine. It runs until the queue is closed
+// and drained.
 func (p *Pool) worker(id int) {
 	defer p.wg.Done()
 
 	for job := range p.jobQueue {
-		err := p.processor.Process(job)
+		err := p.process(job)
 		if job.Result != nil {
 			job.Result <- err
 			close(job.Result)
@@ -62,25 +84,89 @@ func (p *Pool) worker(id int) {
 	}
 }
 
-// Submit adds a job to the queue
-func (p *Pool) Submit(job *types.ExportJob) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// process runs one job, converting a panic into a PanicError so the worker
+// survives to take the next job
+func (p *Pool) process(job *types.ExportJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &types.PanicError{JobID: job.ID, Chunk: -1, Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	err = p.processor.Process(job)
+
+	// Chunk panics are recovered where the job ID is unknown
+	var panicErr *types.PanicError
+	if errors.As(err, &panicErr) && panicErr.JobID == "" {
+		panicErr.JobID = job.ID
+	}
+	return err
+}
+
+// Submit adds a job to the queue without blocking. It returns ErrQueueFull
+// when the queue has no room and ErrPoolClosed after shutdown has begun.
+// A job's Result channel must be buffered unless the caller is guaranteed
+// to receive from it.
+func (p *Pool) Submit(job *types.ExportJob) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
 
-	if !p.stopped {
-		p.jobQueue <- job
+	select {
+	case p.jobQueue <- job:
+		return nil
+	default:
+		return ErrQueueFull
 	}
 }
 
-// Shutdown gracefully shuts down the worker pool
+// SubmitContext adds a job to the queue, waiting for room until ctx is done
+// or shutdown begins
+func (p *Pool) SubmitContext(ctx context.Context, job *types.ExportJob) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.jobQueue <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.quit:
+		return ErrPoolClosed
+	}
+}
+
+// Shutdown stops accepting jobs, waits for the workers to process every job
+// already queued and then returns. It is safe to call more than once.
 func (p *Pool) Shutdown() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	p.quitOnce.Do(func() {
+		close(p.quit)
 
-	if !p.stopped {
-		p.stopped = true
+		p.mu.Lock()
+		p.closed = true
 		close(p.jobQueue)
-		p.wg.Wait()
+		p.mu.Unlock()
+	})
+
+	// Queued jobs are drained even if the pool was never started
+	p.Start()
+	p.wg.Wait()
+}
+
+// Closed reports whether shutdown has begun
+func (p *Pool) Closed() bool {
+	select {
+	case <-p.quit:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -0,0 +1,196 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// processorFunc adapts a function to JobProcessor
+type processorFunc func(job *types.ExportJob) error
+
+func (f processorFunc) Process(job *types.ExportJob) error {
+	return f(job)
+}
+
+func nopProcessor() JobProcessor {
+	return processorFunc(func(*types.ExportJob) error { return nil })
+}
+
+func newJob(id string) *types.ExportJob {
+	return &types.ExportJob{ID: id, Result: make(chan error, 1)}
+}
+
+// within fails the test if fn does not return before the timeout
+func within(t *testing.T, timeout time.Duration, what string, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatalf("%s did not return within %s", what, timeout)
+	}
+}
+
+func TestSubmitAfterShutdown(t *testing.T) {
+	pool := NewPool(1, 1, nopProcessor())
+	pool.Start()
+	pool.Shutdown()
+
+	if err := pool.Submit(newJob("a")); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("Submit after Shutdown = %v, want ErrPoolClosed", err)
+	}
+	within(t, time.Second, "SubmitContext", func() {
+		if err := pool.SubmitContext(context.Background(), newJob("b")); !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("SubmitContext after Shutdown = %v, want ErrPoolClosed", err)
+		}
+	})
+	if !pool.Closed() {
+		t.Fatal("Closed() = false after Shutdown")
+	}
+	// A second Shutdown returns at once
+	within(t, time.Second, "second Shutdown", pool.Shutdown)
+}
+
+func TestSubmitQueueFull(t *testing.T) {
+	// Without started workers nothing leaves the queue
+	pool := NewPool(1, 1, nopProcessor())
+	queued := newJob("queued")
+	if err := pool.Submit(queued); err != nil {
+		t.Fatalf("Submit to empty queue = %v", err)
+	}
+	if err := pool.Submit(newJob("extra")); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit to full queue = %v, want ErrQueueFull", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pool.SubmitContext(ctx, newJob("cancelled")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SubmitContext with cancelled context on full queue = %v, want context.Canceled", err)
+	}
+
+	// Shutdown drains the queued job even though the pool was never started
+	within(t, time.Second, "Shutdown", pool.Shutdown)
+	if err := <-queued.Result; err != nil {
+		t.Fatalf("queued job result = %v", err)
+	}
+}
+
+func TestShutdownReleasesBlockedSubmit(t *testing.T) {
+	pool := NewPool(1, 1, nopProcessor())
+	if err := pool.Submit(newJob("queued")); err != nil {
+		t.Fatalf("Submit to empty queue = %v", err)
+	}
+
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- pool.SubmitContext(context.Background(), newJob("blocked"))
+	}()
+
+	// The submitter waits for room in the full queue
+	select {
+	case err := <-submitted:
+		t.Fatalf("SubmitContext on full queue returned early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	within(t, time.Second, "Shutdown", pool.Shutdown)
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, ErrPoolClosed) {
+			t.Fatalf("blocked SubmitContext = %v, want ErrPoolClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked SubmitContext was not released by Shutdown")
+	}
+}
+
+func TestEveryAcceptedJobGetsOneResult(t *testing.T) {
+	errBoom := errors.New("boom")
+	var processed atomic.Int64
+	pool := NewPool(4, 8, processorFunc(func(job *types.ExportJob) error {
+		processed.Add(1)
+		switch job.ID[0] {
+		case 'e':
+			return errBoom
+		case 'p':
+			panic("job panicked")
+		}
+		return nil
+	}))
+	pool.Start()
+
+	const submitters = 8
+	const perSubmitter = 50
+	ids := []string{"ok", "err", "panic"}
+
+	var (
+		mu       sync.Mutex
+		accepted []*types.ExportJob
+		wg       sync.WaitGroup
+	)
+	for s := 0; s < submitters; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			for i := 0; i < perSubmitter; i++ {
+				job := newJob(ids[(s+i)%len(ids)])
+				err := pool.SubmitContext(context.Background(), job)
+				if err != nil {
+					if !errors.Is(err, ErrPoolClosed) {
+						t.Errorf("SubmitContext = %v, want nil or ErrPoolClosed", err)
+					}
+					continue
+				}
+				mu.Lock()
+				accepted = append(accepted, job)
+				mu.Unlock()
+			}
+		}(s)
+	}
+
+	// Shut down while submissions are still coming in
+	time.Sleep(time.Millisecond)
+	within(t, 5*time.Second, "Shutdown", pool.Shutdown)
+	wg.Wait()
+
+	if got := processed.Load(); got != int64(len(accepted)) {
+		t.Fatalf("processed %d jobs, accepted %d", got, len(accepted))
+	}
+	for _, job := range accepted {
+		var err error
+		select {
+		case err = <-job.Result:
+		default:
+			t.Fatalf("job %s has no result", job.ID)
+		}
+		if _, open := <-job.Result; open {
+			t.Fatalf("job %s got more than one result", job.ID)
+		}
+
+		var panicErr *types.PanicError
+		switch job.ID {
+		case "ok":
+			if err != nil {
+				t.Errorf("job %s result = %v, want nil", job.ID, err)
+			}
+		case "err":
+			if !errors.Is(err, errBoom) {
+				t.Errorf("job %s result = %v, want %v", job.ID, err, errBoom)
+			}
+		case "panic":
+			if !errors.As(err, &panicErr) || panicErr.JobID != job.ID {
+				t.Errorf("job %s result = %v, want a PanicError of the job", job.ID, err)
+			}
+		}
+	}
+}
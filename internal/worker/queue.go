@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"context"
 	"sync"
 
 	"github.com/turbo-export-engine/pkg/types"
@@ -41,12 +42,23 @@ func (q *Queue) Start() {
 	q.pool.Start()
 }
 
-// Submit adds a job to the global queue
-func (q *Queue) Submit(job *types.ExportJob) {
-	q.pool.Submit(job)
+// Submit adds a job to the global queue without blocking
+func (q *Queue) Submit(job *types.ExportJob) error {
+	return q.pool.Submit(job)
 }
 
-// Shutdown gracefully shuts down the queue
+// SubmitContext adds a job to the global queue, waiting for room until ctx
+// is done or the queue shuts down
+func (q *Queue) SubmitContext(ctx context.Context, job *types.ExportJob) error {
+	return q.pool.SubmitContext(ctx, job)
+}
+
+// Shutdown stops accepting jobs and waits for queued jobs to finish
 func (q *Queue) Shutdown() {
 	q.pool.Shutdown()
 }
+
+// Closed reports whether the queue has begun shutting down
+func (q *Queue) Closed() bool {
+	return q.pool.Closed()
+}
@@ -3,12 +3,15 @@ package xlsx
 import (
 	"archive/zip"
 	"bufio"
+	"context"
 	"fmt"
-	"html"
+	"io"
 	"os"
-	"strings"
 	"sync"
 
+	"github.com/turbo-export-engine/internal/chunk"
+	"github.com/turbo-export-engine/internal/missing"
+	"github.com/turbo-export-engine/internal/output"
 	"github.com/turbo-export-engine/pkg/types"
 )
 
@@ -16,6 +19,7 @@ import (
 type Builder struct {
 	config *types.ExportConfig
 	mu     sync.Mutex
+	sheets []types.SheetResult
 }
 
 // NewBuilder creates a new XLSX builder
@@ -25,137 +29,240 @@ func NewBuilder(config *types.ExportConfig) *Builder {
 	}
 }
 
-// Build creates an XLSX file with the given data
-func (b *Builder) Build(headers []string, rows []types.Row) error {
+// Build creates an XLSX file with rows pulled from the source. The output
+// file is removed if building fails or ctx is cancelled.
+func (b *Builder) Build(ctx context.Context, headers []string, src types.RowSource) error {
+	return b.BuildSheets(ctx, types.NewSheetSource([]types.Sheet{{Headers: headers, Source: src}}))
+}
+
+// BuildSheets creates an XLSX file with one worksheet per sheet, streaming
+// the sheets in order. The output file is removed if building fails or ctx
+// is cancelled.
+func (b *Builder) BuildSheets(ctx context.Context, sheets types.SheetSource) error {
 	// Create output file
-	file, err := os.Create(b.config.OutputPath)
+	file, err := output.Open(b.config.OutputPath, b.config.Output)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
 	}
-	defer file.Close()
+	defer file.Discard()
 
-	// Create zip writer
+	// The zip writer is only closed on success; an aborted build leaves
+	// the archive without its central directory
 	zipWriter := zip.NewWriter(file)
-	defer zipWriter.Close()
-
-	// Write [Content_Types].xml
-	if err := b.writeContentTypes(zipWriter); err != nil {
+	wb := newWorkbook(b.config.XLSX)
+	if err := wb.open(zipWriter); err != nil {
 		return err
 	}
 
-	// Write _rels/.rels
-	if err := b.writeRels(zipWriter); err != nil {
-		return err
+	// Write xl/worksheets/sheetN.xml (streaming)
+	for sheets.Next() {
+		sheet := sheets.Sheet()
+		if err := b.buildSheet(ctx, zipWriter, wb, sheet); err != nil {
+			return err
+		}
+	}
+	if err := sheets.Err(); err != nil {
+		return fmt.Errorf("failed to read sheets: %w", err)
 	}
 
-	// Write xl/_rels/workbook.xml.rels
-	if err := b.writeWorkbookRels(zipWriter); err != nil {
-		return err
+	// A workbook needs at least one sheet
+	if len(wb.sheets) == 0 {
+		empty := types.Sheet{Source: types.NewSliceSource(nil)}
+		if err := b.buildSheet(ctx, zipWriter, wb, empty); err != nil {
+			return err
+		}
 	}
 
-	// Write xl/workbook.xml
-	if err := b.writeWorkbook(zipWriter); err != nil {
+	// Write the workbook, styles and shared strings now that every sheet
+	// is known
+	if err := wb.close(zipWriter); err != nil {
 		return err
 	}
 
-	// Write xl/worksheets/sheet1.xml (streaming)
-	if err := b.writeSheet(zipWriter, headers, rows); err != nil {
+	// Closing writes the central directory, which completes the file
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finalize xlsx: %w", err)
+	}
+	if err := file.Close(); err != nil {
 		return err
 	}
 
+	b.mu.Lock()
+	b.sheets = wb.sheets
+	b.mu.Unlock()
 	return nil
 }
 
-func (b *Builder) writeContentTypes(zw *zip.Writer) error {
-	w, err := zw.Create("[Content_Types].xml")
+// Sheets returns the worksheets written by the last successful build
+func (b *Builder) Sheets() []types.SheetResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]types.SheetResult(nil), b.sheets...)
+}
+
+// buildSheet writes the rows of one sheet, rolling them over into further
+// sheets whenever a sheet reaches the row limit
+func (b *Builder) buildSheet(ctx context.Context, zw *zip.Writer, wb *workbook, sheet types.Sheet) error {
+	opts := b.config.XLSX
+
+	fitted, err := missing.Fit(sheet.Source, sheet.Headers, b.config.Missing)
 	if err != nil {
 		return err
 	}
 
-	content := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
-  <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
-  <Default Extension="xml" ContentType="application/xml"/>
-  <Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
-  <Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
-</Types>`
+	// Rows held in memory give the dimension of each sheet up front
+	var pending []types.Row
+	memory, known := fitted.(types.MemorySource)
+	if known {
+		pending = memory.Pending()
+	}
+	src := chunk.WithContext(ctx, fitted)
 
-	_, err = w.Write([]byte(content))
-	return err
-}
+	for part := 1; ; part++ {
+		path, err := wb.addSheet(rolloverName(sheet.Name, part))
+		if err != nil {
+			return err
+		}
 
-func (b *Builder) writeRels(zw *zip.Writer) error {
-	w, err := zw.Create("_rels/.rels")
-	if err != nil {
-		return err
-	}
+		r, err := newRenderer(sheet.Headers, opts, valueOptions{b.config.Injection, b.config.Missing, b.config.Numbers}, wb.styles, wb.shared)
+		if err != nil {
+			return err
+		}
 
-	content := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
-  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
-</Relationships>`
+		var headerRow []string
+		if part == 1 || opts.RepeatHeaders {
+			headerRow = sheet.Headers
+		}
+		rows := &sheetRows{RowSource: src, max: sheetCapacity(opts, len(headerRow) > 0)}
 
-	_, err = w.Write([]byte(content))
-	return err
-}
+		var dimension string
+		if known {
+			n := rows.max
+			if n > len(pending) {
+				n = len(pending)
+			}
+			dimension = sheetDimension(headerRow, pending[:n])
+			pending = pending[n:]
+		}
 
-func (b *Builder) writeWorkbookRels(zw *zip.Writer) error {
-	w, err := zw.Create("xl/_rels/workbook.xml.rels")
-	if err != nil {
-		return err
-	}
+		// Column widths precede the rows, so they are estimated from the
+		// first rows before any is written
+		var sheetSrc types.RowSource = rows
+		if opts.AutoWidth {
+			var sample []types.Row
+			sample, sheetSrc, err = chunk.Peek(sheetSrc, widthSampleRows)
+			if err != nil {
+				return fmt.Errorf("failed to read rows: %w", err)
+			}
+			r.estimateWidths(headerRow, sample)
+		}
 
-	content := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
-  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
-</Relationships>`
+		if err := b.writeSheet(ctx, zw, wb, path, r, headerRow, sheetSrc, dimension); err != nil {
+			return err
+		}
+		wb.setRows(rows.count)
+
+		if !rows.full() {
+			return nil
+		}
 
-	_, err = w.Write([]byte(content))
-	return err
+		// Roll over only if rows remain
+		var next []types.Row
+		next, src, err = chunk.Peek(src, 1)
+		if err != nil {
+			return fmt.Errorf("failed to read rows: %w", err)
+		}
+		if len(next) == 0 {
+			return nil
+		}
+	}
 }
 
-func (b *Builder) writeWorkbook(zw *zip.Writer) error {
-	w, err := zw.Create("xl/workbook.xml")
+// renderedRows is the XML of a chunk of rows
+type renderedRows struct {
+	xml     string
+	rows    int
+	cols    int            // cells in the longest row
+	strings *sharedStrings // shared strings of the chunk, nil when inline
+	links   *hyperlinks    // hyperlinks of the chunk
+}
+
+// writeSheet streams one worksheet, starting with headerRow unless it is
+// empty. The sheet's dimension is written ahead of the rows when known;
+// otherwise, if BufferSheets is set, the rows are spooled to a temporary
+// file until it is.
+func (b *Builder) writeSheet(ctx context.Context, zw *zip.Writer, wb *workbook, path string, r *renderer, headerRow []string, src types.RowSource, dimension string) error {
+	w, err := zw.Create(path)
 	if err != nil {
 		return err
 	}
 
-	content := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
-  <sheets>
-    <sheet name="Sheet1" sheetId="1" r:id="rId1"/>
-  </sheets>
-</workbook>`
+	buffered := bufio.NewWriterSize(w, 128*1024)
+	body := buffered
 
-	_, err = w.Write([]byte(content))
-	return err
-}
+	var spool *os.File
+	if dimension == "" && b.config.XLSX.BufferSheets {
+		spool, err = os.CreateTemp("", "export-engine-sheet-*.xml")
+		if err != nil {
+			return fmt.Errorf("failed to create sheet buffer: %w", err)
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		body = bufio.NewWriterSize(spool, 128*1024)
+	} else {
+		// Write header
+		if _, err := buffered.WriteString(r.sheetHeader(len(headerRow) > 0, dimension)); err != nil {
+			return err
+		}
+	}
 
-func (b *Builder) writeSheet(zw *zip.Writer, headers []string, rows []types.Row) error {
-	w, err := zw.Create("xl/worksheets/sheet1.xml")
+	rows, cols, err := b.writeRows(ctx, body, r, headerRow, src)
 	if err != nil {
 		return err
 	}
 
-	buffered := bufio.NewWriterSize(w, 128*1024)
-	defer buffered.Flush()
-
-	// Write header
-	header := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
-  <sheetData>
-`
-	if _, err := buffered.WriteString(header); err != nil {
+	if spool != nil {
+		if err := body.Flush(); err != nil {
+			return fmt.Errorf("failed to write sheet buffer: %w", err)
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to read sheet buffer: %w", err)
+		}
+		if _, err := buffered.WriteString(r.sheetHeader(len(headerRow) > 0, dimensionRef(rows, cols))); err != nil {
+			return err
+		}
+		if _, err := io.Copy(buffered, spool); err != nil {
+			return fmt.Errorf("failed to read sheet buffer: %w", err)
+		}
+	}
+
+	// Write footer
+	dataRows := rows
+	if len(headerRow) > 0 {
+		dataRows--
+	}
+	footer := wb.sheetEnd(b.config.XLSX, r, headerRow, dataRows)
+	if _, err := buffered.WriteString(footer); err != nil {
 		return err
 	}
 
+	return buffered.Flush()
+}
+
+// writeRows writes headerRow, unless it is empty, and the rows of src to
+// w. It returns the number of rows written and the cells in the longest.
+func (b *Builder) writeRows(ctx context.Context, w *bufio.Writer, r *renderer, headerRow []string, src types.RowSource) (int, int, error) {
 	rowNum := 1
+	cols := len(headerRow)
 
 	// Write header row
-	if len(headers) > 0 {
-		rowXML := b.buildRowXML(rowNum, headers)
-		if _, err := buffered.WriteString(rowXML); err != nil {
-			return err
+	if len(headerRow) > 0 {
+		rowXML, err := r.headerRow(rowNum, headerRow)
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, err := w.WriteString(rowXML); err != nil {
+			return 0, 0, err
 		}
 		rowNum++
 	}
@@ -163,17 +270,23 @@ func (b *Builder) writeSheet(zw *zip.Writer, headers []string, rows []types.Row)
 	// Process rows based on mode
 	if b.config.Mode == types.ModeSync {
 		// Write rows synchronously
-		for _, row := range rows {
-			cells := make([]string, len(row))
-			for i, cell := range row {
-				cells[i] = fmt.Sprintf("%v", cell)
+		for src.Next() {
+			row := src.Row()
+			rowXML, err := r.row(rowNum, row)
+			if err != nil {
+				return 0, 0, err
+			}
+			if _, err := w.WriteString(rowXML); err != nil {
+				return 0, 0, err
 			}
-			rowXML := b.buildRowXML(rowNum, cells)
-			if _, err := buffered.WriteString(rowXML); err != nil {
-				return err
+			if len(row) > cols {
+				cols = len(row)
 			}
 			rowNum++
 		}
+		if err := src.Err(); err != nil {
+			return 0, 0, fmt.Errorf("failed to read rows: %w", err)
+		}
 	} else {
 		// Write rows with parallel processing
 		chunkSize := b.config.ChunkSize
@@ -186,125 +299,39 @@ func (b *Builder) writeSheet(zw *zip.Writer, headers []string, rows []types.Row)
 			workers = 4
 		}
 
-		chunks := splitIntoChunks(rows, chunkSize)
-		resultChan := make(chan processedChunk, len(chunks))
-		errChan := make(chan error, workers)
-
-		var wg sync.WaitGroup
-		semaphore := make(chan struct{}, workers)
-
-		for idx, chunk := range chunks {
-			wg.Add(1)
-			go func(chunkIdx int, chunkData []types.Row, startRow int) {
-				defer wg.Done()
-				semaphore <- struct{}{}
-				defer func() { <-semaphore }()
-
-				processed, err := b.processChunkXML(chunkIdx, chunkData, startRow)
-				if err != nil {
-					errChan <- err
-					return
-				}
-				resultChan <- processed
-			}(idx, chunk, rowNum+idx*chunkSize)
-		}
-
-		go func() {
-			wg.Wait()
-			close(resultChan)
-			close(errChan)
-		}()
-
-		// Collect results in order
-		results := make([]processedChunk, len(chunks))
-		for result := range resultChan {
-			results[result.Index] = result
-		}
-
-		// Check for errors
-		select {
-		case err := <-errChan:
+		startRow := rowNum
+		process := func(index, offset int, chunkData []types.Row) (renderedRows, error) {
+			chunkRenderer := r.chunkRenderer()
+			xml, err := chunkRenderer.rows(startRow+offset, chunkData)
 			if err != nil {
-				return err
+				return renderedRows{}, err
+			}
+			rendered := renderedRows{xml: xml, rows: len(chunkData), strings: chunkRenderer.shared, links: chunkRenderer.links}
+			for _, row := range chunkData {
+				if len(row) > rendered.cols {
+					rendered.cols = len(row)
+				}
 			}
-		default:
+			return rendered, nil
 		}
 
 		// Write results in order
-		for _, result := range results {
-			if _, err := buffered.WriteString(result.XML); err != nil {
-				return err
+		err := chunk.Ordered(ctx, src, chunkSize, workers, process, func(rendered renderedRows) error {
+			rowNum += rendered.rows
+			if rendered.cols > cols {
+				cols = rendered.cols
 			}
+			xml := r.links.merge(rendered.links, rendered.xml, r.plainStyles())
+			if rendered.strings != nil {
+				xml = r.shared.merge(rendered.strings, xml)
+			}
+			_, err := w.WriteString(xml)
+			return err
+		})
+		if err != nil {
+			return 0, 0, err
 		}
 	}
 
-	// Write footer
-	footer := `  </sheetData>
-</worksheet>`
-	if _, err := buffered.WriteString(footer); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (b *Builder) buildRowXML(rowNum int, cells []string) string {
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("    <row r=\"%d\">", rowNum))
-
-	for colIdx, cellValue := range cells {
-		colName := columnName(colIdx)
-		cellRef := fmt.Sprintf("%s%d", colName, rowNum)
-		escapedValue := html.EscapeString(cellValue)
-
-		sb.WriteString(fmt.Sprintf("<c r=\"%s\" t=\"inlineStr\"><is><t>%s</t></is></c>",
-			cellRef, escapedValue))
-	}
-
-	sb.WriteString("</row>\n")
-	return sb.String()
-}
-
-type processedChunk struct {
-	Index int
-	XML   string
-}
-
-func (b *Builder) processChunkXML(index int, rows []types.Row, startRowNum int) (processedChunk, error) {
-	var sb strings.Builder
-
-	for i, row := range rows {
-		cells := make([]string, len(row))
-		for j, cell := range row {
-			cells[j] = fmt.Sprintf("%v", cell)
-		}
-		rowXML := b.buildRowXML(startRowNum+i, cells)
-		sb.WriteString(rowXML)
-	}
-
-	return processedChunk{Index: index, XML: sb.String()}, nil
-}
-
-func splitIntoChunks(rows []types.Row, chunkSize int) [][]types.Row {
-	var chunks [][]types.Row
-	for i := 0; i < len(rows); i += chunkSize {
-		end := i + chunkSize
-		if end > len(rows) {
-			end = len(rows)
-		}
-		chunks = append(chunks, rows[i:end])
-	}
-	return chunks
-}
-
-// columnName converts a column index to Excel column name (A, B, ..., Z, AA, AB, ...)
-func columnName(col int) string {
-	name := ""
-	col++ // Excel columns are 1-based
-	for col > 0 {
-		col--
-		name = string(rune('A'+(col%26))) + name
-		col /= 26
-	}
-	return name
+	return rowNum - 1, cols, nil
 }
@@ -0,0 +1,554 @@
+package xlsx
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbo-export-engine/internal/injection"
+	"github.com/turbo-export-engine/internal/values"
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// excelEpoch is day zero of the 1900 date system
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// dateLayouts are the string formats recognised as dates
+var dateLayouts = []struct {
+	layout   string
+	dateOnly bool
+}{
+	{"2006-01-02", true},
+	{time.RFC3339Nano, false},
+	{"2006-01-02T15:04:05", false},
+	{"2006-01-02 15:04:05", false},
+}
+
+// column describes how the cells of one column are rendered
+type column struct {
+	cellType types.CellType // declared type, empty when undeclared
+	style    int            // number format style, 0 when unformatted
+	width    float64        // width in characters, 0 for the default
+}
+
+// valueOptions are the options every writer shares that shape cell values
+type valueOptions struct {
+	injection types.InjectionOptions
+	missing   types.MissingOptions
+	numbers   types.NumberOptions
+}
+
+// renderer turns rows into worksheet XML. It is read-only once built apart
+// from the shared strings table and the hyperlinks it collects, so chunks
+// of a sheet can be rendered concurrently by the renderers of
+// chunkRenderer.
+type renderer struct {
+	columns       []column
+	detectDates   bool
+	dateStyle     int
+	dateTimeStyle int
+	headerStyle   int
+	linkStyle     int
+	freezeHeader  bool
+	text          textPolicy
+	values        values.Formatter
+	guard         *injection.Guard // nil when no column is protected
+	quoted        map[int]int      // quote-prefixed variants of the text styles
+	null          string           // text of null cells, empty to omit them
+	tableColumns  []string         // names of the table columns, nil without a table
+	shared        *sharedStrings   // nil when strings are written inline
+	links         *hyperlinks
+}
+
+// newRenderer builds the renderer for a sheet, registering the cell formats
+// it uses in styles. Values are formatted and protected by cells, and text
+// cells go to shared when it is not nil.
+func newRenderer(headers []string, opts types.XLSXOptions, cells valueOptions, styles *styleSheet, shared *sharedStrings) (*renderer, error) {
+	text, err := newTextPolicy(opts)
+	if err != nil {
+		return nil, err
+	}
+	format, err := values.NewFormatter(cells.numbers)
+	if err != nil {
+		return nil, err
+	}
+	guard, err := injection.NewGuard(cells.injection, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &renderer{
+		columns:       make([]column, len(headers)),
+		detectDates:   opts.DetectDates,
+		freezeHeader:  opts.FreezeHeader,
+		text:          text,
+		values:        format,
+		guard:         guard,
+		null:          cells.missing.Null,
+		shared:        shared,
+		links:         newHyperlinks(),
+		dateStyle:     styles.add(cellStyle{numFmtID: styles.numFmt(dateFormat)}),
+		dateTimeStyle: styles.add(cellStyle{numFmtID: styles.numFmt(dateTimeFormat)}),
+	}
+
+	var header cellStyle
+	if opts.BoldHeaders {
+		header.fontID = styles.font(font{bold: true})
+	}
+	if opts.HeaderFill != "" {
+		argb, err := fillColor(opts.HeaderFill)
+		if err != nil {
+			return nil, err
+		}
+		header.fillID = styles.fill(argb)
+	}
+	r.headerStyle = styles.add(header)
+
+	if opts.Table && len(headers) > 0 {
+		columns, err := r.headerTexts(headers)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTable(opts, columns); err != nil {
+			return nil, err
+		}
+		r.tableColumns = columns
+	}
+
+	for i, name := range headers {
+		col := &r.columns[i]
+		col.cellType = opts.ColumnTypes[name]
+		if col.cellType == types.CellHyperlink && r.linkStyle == 0 {
+			r.linkStyle = styles.add(cellStyle{fontID: styles.font(font{link: true})})
+		}
+		if format, ok := opts.NumberFormats[name]; ok {
+			col.style = styles.add(cellStyle{numFmtID: styles.numFmt(numFmtCode(format))})
+		}
+		if width, ok := opts.ColumnWidths[name]; ok {
+			if width <= 0 || width > maxColumnWidth {
+				return nil, fmt.Errorf("invalid width %v for column %q: expected 0 to %d", width, name, maxColumnWidth)
+			}
+			col.width = width
+		}
+	}
+
+	// Text the prefix policy protects is marked by its style rather than
+	// changed, so the cell keeps its value
+	if guard.Prefixes() {
+		r.quoted = make(map[int]int)
+		for _, style := range []int{0, r.headerStyle, r.linkStyle} {
+			r.quoted[style] = styles.quoted(style)
+		}
+	}
+	return r, nil
+}
+
+// headerRow renders the header row; header cells are always text
+func (r *renderer) headerRow(rowNum int, headers []string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("    <row r=\"%d\">", rowNum))
+	for col, name := range headers {
+		if err := r.writeString(&sb, cellRef(col, rowNum), col, name, r.headerStyle); err != nil {
+			return "", err
+		}
+	}
+	sb.WriteString("</row>\n")
+	return sb.String(), nil
+}
+
+// chunkRenderer returns a renderer like r for rendering a chunk of rows
+// ahead of its turn, collecting its own hyperlinks and, unless strings are
+// written inline, its own shared strings. Both are merged into r's when
+// the chunk is written, so strings are numbered and links capped in row
+// order.
+func (r *renderer) chunkRenderer() *renderer {
+	chunk := *r
+	chunk.links = newChunkLinks()
+	if r.shared != nil {
+		chunk.shared = newChunkStrings()
+	}
+	return &chunk
+}
+
+// headerTexts returns the text of the header cells, protected and
+// sanitized as headerRow writes them
+func (r *renderer) headerTexts(headers []string) ([]string, error) {
+	texts := make([]string, len(headers))
+	for col, name := range headers {
+		text, _, err := r.guard.Protect(col, name)
+		if err == nil {
+			texts[col], err = r.text.cell(text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cell %s: %w", cellRef(col, 1), err)
+		}
+	}
+	return texts, nil
+}
+
+// row renders one data row
+func (r *renderer) row(rowNum int, row types.Row) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("    <row r=\"%d\">", rowNum))
+	for col, value := range row {
+		if err := r.writeCell(&sb, rowNum, col, value); err != nil {
+			return "", err
+		}
+	}
+	sb.WriteString("</row>\n")
+	return sb.String(), nil
+}
+
+// rows renders consecutive rows starting at startRowNum
+func (r *renderer) rows(startRowNum int, rows []types.Row) (string, error) {
+	var sb strings.Builder
+	for i, row := range rows {
+		rowXML, err := r.row(startRowNum+i, row)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(rowXML)
+	}
+	return sb.String(), nil
+}
+
+// column returns the description of column col; columns beyond the
+// headers are undeclared and unformatted
+func (r *renderer) column(col int) column {
+	if col < len(r.columns) {
+		return r.columns[col]
+	}
+	return column{}
+}
+
+// dateStyleFor returns the style of a date cell in column c, preferring
+// the column's own number format
+func (r *renderer) dateStyleFor(c column, dateOnly bool) int {
+	switch {
+	case c.style != 0:
+		return c.style
+	case dateOnly:
+		return r.dateStyle
+	default:
+		return r.dateTimeStyle
+	}
+}
+
+// writeCell writes value typed by its column declaration, or by its own
+// type when the column is undeclared. Values that do not fit the declared
+// type are written as text.
+func (r *renderer) writeCell(sb *strings.Builder, rowNum, col int, value interface{}) error {
+	ref := cellRef(col, rowNum)
+	if value == nil {
+		if r.null == "" {
+			return nil
+		}
+		return r.writeText(sb, ref, r.null, 0)
+	}
+	c := r.column(col)
+	switch c.cellType {
+	case types.CellString:
+	case types.CellHyperlink:
+		if target, text := hyperlinkValue(value); text != "" {
+			if !validTarget(target) {
+				return r.writeString(sb, ref, col, text, 0)
+			}
+			// Links beyond the sheet's limit are left as plain text
+			if !r.links.add(hyperlink{row: rowNum, col: col, target: target}) {
+				return r.writeString(sb, ref, col, text, 0)
+			}
+			return r.writeString(sb, ref, col, text, r.linkStyle)
+		}
+	case types.CellFormula:
+		if s, ok := value.(string); ok {
+			if formula, ok := formulaText(s, rowNum); ok {
+				writeFormulaCell(sb, ref, formula, c.style)
+				return nil
+			}
+		} else if r.writeDetected(sb, ref, c, value) {
+			return nil
+		}
+	case types.CellNumber:
+		if literal, ok := numberLiteral(value, true); ok {
+			writeNumberCell(sb, ref, literal, c.style)
+			return nil
+		}
+	case types.CellBool:
+		if b, ok := boolValue(value, true); ok {
+			writeBoolCell(sb, ref, b)
+			return nil
+		}
+	case types.CellDate, types.CellDateTime:
+		style := r.dateStyleFor(c, c.cellType == types.CellDate)
+		if t, _, ok := timeValue(value); ok {
+			if serial, ok := excelSerial(t); ok {
+				writeNumberCell(sb, ref, serial, style)
+				return nil
+			}
+		}
+		// Numbers are taken as serial dates already
+		if literal, ok := numberLiteral(value, false); ok {
+			writeNumberCell(sb, ref, literal, style)
+			return nil
+		}
+	default:
+		if r.writeDetected(sb, ref, c, value) {
+			return nil
+		}
+	}
+
+	return r.writeString(sb, ref, col, r.values.Text(value), 0)
+}
+
+// writeString writes a text cell in column col referencing the shared
+// strings table, or an inline string when there is no table or it is full.
+// The text is protected by the column's injection policy and sanitized by
+// the renderer's text policy first; prefixed text gets a quote-prefixed
+// style.
+func (r *renderer) writeString(sb *strings.Builder, ref string, col int, value string, style int) error {
+	value, quote, err := r.guard.Protect(col, value)
+	if err != nil {
+		return fmt.Errorf("cell %s: %w", ref, err)
+	}
+	if quote {
+		style = r.quoted[style]
+	}
+	return r.writeText(sb, ref, value, style)
+}
+
+// writeText writes a text cell like writeString without injection
+// protection
+func (r *renderer) writeText(sb *strings.Builder, ref, value string, style int) error {
+	value, err := r.text.cell(value)
+	if err != nil {
+		return fmt.Errorf("cell %s: %w", ref, err)
+	}
+	if r.shared != nil {
+		if idx, ok := r.shared.add(value); ok {
+			writeSharedStringCell(sb, ref, idx, style)
+			return nil
+		}
+	}
+	writeStringCell(sb, ref, value, style)
+	return nil
+}
+
+// writeDetected writes numbers, booleans and, if enabled, dates by the
+// value's own type. It reports false for values that are written as text.
+func (r *renderer) writeDetected(sb *strings.Builder, ref string, c column, value interface{}) bool {
+	if b, ok := boolValue(value, false); ok {
+		writeBoolCell(sb, ref, b)
+		return true
+	}
+	if literal, ok := numberLiteral(value, false); ok {
+		writeNumberCell(sb, ref, literal, c.style)
+		return true
+	}
+
+	if _, isString := value.(string); isString && !r.detectDates {
+		return false
+	}
+	t, dateOnly, ok := timeValue(value)
+	if !ok {
+		return false
+	}
+	serial, ok := excelSerial(t)
+	if !ok {
+		return false
+	}
+	writeNumberCell(sb, ref, serial, r.dateStyleFor(c, dateOnly))
+	return true
+}
+
+// numberLiteral formats numeric values for a <v> element. With parse set,
+// numeric strings are accepted too.
+func numberLiteral(value interface{}, parse bool) (string, bool) {
+	switch v := value.(type) {
+	case float64:
+		return floatLiteral(v)
+	case float32:
+		return floatLiteral(float64(v))
+	case json.Number:
+		if values.IsPlainDecimal(string(v)) {
+			return string(v), true
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return "", false
+		}
+		return floatLiteral(f)
+	case int:
+		return strconv.FormatInt(int64(v), 10), true
+	case int8:
+		return strconv.FormatInt(int64(v), 10), true
+	case int16:
+		return strconv.FormatInt(int64(v), 10), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case string:
+		if !parse {
+			return "", false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return "", false
+		}
+		return floatLiteral(f)
+	}
+	return "", false
+}
+
+// floatLiteral formats a float without exponent; NaN and infinities have no
+// XLSX representation
+func floatLiteral(f float64) (string, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), true
+}
+
+// boolValue accepts booleans and, with parse set, their string forms
+func boolValue(value interface{}, parse bool) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		if !parse {
+			return false, false
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	}
+	return false, false
+}
+
+// timeValue accepts time.Time values and date strings, reporting whether
+// the value carries a date without a time of day
+func timeValue(value interface{}) (time.Time, bool, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, false, true
+	case string:
+		return parseDate(v)
+	}
+	return time.Time{}, false, false
+}
+
+func parseDate(s string) (time.Time, bool, bool) {
+	// Cheap rejection of the strings that cannot be dates
+	if len(s) < 10 || s[4] != '-' || s[7] != '-' {
+		return time.Time{}, false, false
+	}
+	for _, candidate := range dateLayouts {
+		if t, err := time.Parse(candidate.layout, s); err == nil {
+			return t, candidate.dateOnly, true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+// excelSerial converts t to an Excel serial date, keeping the wall clock of
+// t's own zone since Excel dates have none. Dates before 1900 cannot be
+// represented.
+func excelSerial(t time.Time) (string, bool) {
+	if t.Year() < 1900 {
+		return "", false
+	}
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	days := float64(wall.Unix()-excelEpoch.Unix()) / 86400
+	days += float64(wall.Nanosecond()) / 86400e9
+
+	// Excel counts the non-existent 1900-02-29, shifting earlier dates
+	if days < 61 {
+		days--
+	}
+	return strconv.FormatFloat(days, 'f', -1, 64), true
+}
+
+func writeStringCell(sb *strings.Builder, ref, value string, style int) {
+	sb.WriteString(`<c r="`)
+	sb.WriteString(ref)
+	writeStyleAttr(sb, style)
+	sb.WriteString(`" t="inlineStr"><is><t>`)
+	sb.WriteString(xmlText(value))
+	sb.WriteString(`</t></is></c>`)
+}
+
+func writeFormulaCell(sb *strings.Builder, ref, formula string, style int) {
+	sb.WriteString(`<c r="`)
+	sb.WriteString(ref)
+	writeStyleAttr(sb, style)
+	sb.WriteString(`"><f>`)
+	sb.WriteString(html.EscapeString(formula))
+	sb.WriteString(`</f></c>`)
+}
+
+func writeSharedStringCell(sb *strings.Builder, ref string, idx, style int) {
+	sb.WriteString(`<c r="`)
+	sb.WriteString(ref)
+	writeStyleAttr(sb, style)
+	sb.WriteString(`" t="s"><v>`)
+	sb.WriteString(strconv.Itoa(idx))
+	sb.WriteString(`</v></c>`)
+}
+
+func writeNumberCell(sb *strings.Builder, ref, literal string, style int) {
+	sb.WriteString(`<c r="`)
+	sb.WriteString(ref)
+	writeStyleAttr(sb, style)
+	sb.WriteString(`"><v>`)
+	sb.WriteString(literal)
+	sb.WriteString(`</v></c>`)
+}
+
+// writeStyleAttr continues an open r attribute with the s attribute of a
+// styled cell
+func writeStyleAttr(sb *strings.Builder, style int) {
+	if style != 0 {
+		sb.WriteString(`" s="`)
+		sb.WriteString(strconv.Itoa(style))
+	}
+}
+
+func writeBoolCell(sb *strings.Builder, ref string, value bool) {
+	sb.WriteString(`<c r="`)
+	sb.WriteString(ref)
+	if value {
+		sb.WriteString(`" t="b"><v>1</v></c>`)
+	} else {
+		sb.WriteString(`" t="b"><v>0</v></c>`)
+	}
+}
+
+// cellRef returns the A1-style reference of a cell
+func cellRef(col, rowNum int) string {
+	return columnName(col) + strconv.Itoa(rowNum)
+}
+
+// columnName converts a column index to Excel column name (A, B, ..., Z, AA, AB, ...)
+func columnName(col int) string {
+	name := ""
+	col++ // Excel columns are 1-based
+	for col > 0 {
+		col--
+		name = string(rune('A'+(col%26))) + name
+		col /= 26
+	}
+	return name
+}
@@ -0,0 +1,150 @@
+package xlsx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// maxColumnWidth is the widest column Excel allows, in characters
+const maxColumnWidth = 255
+
+// defaultColumnWidth is Excel's default width of Calibri 11 columns
+const defaultColumnWidth = 9.140625
+
+// frozenHeaderView keeps the first row in view while scrolling
+const frozenHeaderView = `  <sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/><selection pane="bottomLeft" activeCell="A2" sqref="A2"/></sheetView></sheetViews>
+`
+
+// Auto width estimation bounds. Estimated widths are capped well below
+// maxColumnWidth so a single long value does not swallow the screen.
+const (
+	widthSampleRows = 1000
+	widthPadding    = 2
+	minAutoWidth    = 8
+	maxAutoWidth    = 60
+)
+
+// estimateWidths sets the width of the columns without one from the
+// display length of the headers and sampled rows
+func (r *renderer) estimateWidths(headers []string, sample []types.Row) {
+	lengths := make([]int, len(r.columns))
+	for i, name := range headers {
+		if i < len(lengths) {
+			lengths[i] = utf8.RuneCountInString(name)
+		}
+	}
+	for _, row := range sample {
+		for i, value := range row {
+			if i >= len(lengths) {
+				break
+			}
+			text := r.null
+			if value != nil {
+				text = r.values.Text(value)
+			}
+			if r.columns[i].cellType == types.CellHyperlink {
+				if _, display := hyperlinkValue(value); display != "" {
+					text = display
+				}
+			}
+			n := utf8.RuneCountInString(text)
+			if r.columns[i].style != 0 {
+				// Allow for thousands separators and currency symbols
+				n += n/3 + 1
+			}
+			if n > lengths[i] {
+				lengths[i] = n
+			}
+		}
+	}
+
+	for i := range r.columns {
+		if r.columns[i].width != 0 {
+			continue
+		}
+		width := lengths[i] + widthPadding
+		if width < minAutoWidth {
+			width = minAutoWidth
+		}
+		if width > maxAutoWidth {
+			width = maxAutoWidth
+		}
+		r.columns[i].width = float64(width)
+	}
+}
+
+// sheetHeader renders the start of the worksheet up to <sheetData>,
+// including the used range dimension unless it is empty. The header row
+// is frozen if enabled and the sheet has one.
+func (r *renderer) sheetHeader(headerRow bool, dimension string) string {
+	var sb strings.Builder
+	sb.WriteString(sheetStart)
+	if dimension != "" {
+		sb.WriteString(fmt.Sprintf("  <dimension ref=\"%s\"/>\n", dimension))
+	}
+	if r.freezeHeader && headerRow {
+		sb.WriteString(frozenHeaderView)
+	}
+	r.writeCols(&sb)
+	sb.WriteString(sheetDataStart)
+	return sb.String()
+}
+
+// writeCols writes the <cols> element describing every header column, in
+// runs of adjacent columns sharing a width. Columns without a width get
+// Excel's default one.
+func (r *renderer) writeCols(sb *strings.Builder) {
+	if len(r.columns) == 0 {
+		return
+	}
+	sb.WriteString("  <cols>")
+	for start := 0; start < len(r.columns); {
+		width := r.columns[start].width
+		end := start
+		for end+1 < len(r.columns) && r.columns[end+1].width == width {
+			end++
+		}
+		sb.WriteString(`<col min="` + strconv.Itoa(start+1) + `" max="` + strconv.Itoa(end+1) + `" width="`)
+		if width == 0 {
+			sb.WriteString(strconv.FormatFloat(defaultColumnWidth, 'f', -1, 64) + `"/>`)
+		} else {
+			sb.WriteString(strconv.FormatFloat(width, 'f', -1, 64) + `" customWidth="1"/>`)
+		}
+		start = end + 1
+	}
+	sb.WriteString("</cols>\n")
+}
+
+// sheetDimension returns the used range of a sheet holding headerRow, if
+// not empty, followed by rows
+func sheetDimension(headerRow []string, rows []types.Row) string {
+	n, cols := len(rows), len(headerRow)
+	if len(headerRow) > 0 {
+		n++
+	}
+	for _, row := range rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	return dimensionRef(n, cols)
+}
+
+// dimensionRef returns the range of rows rows and cols columns starting at
+// A1. Empty sheets span A1 alone, as Excel writes them.
+func dimensionRef(rows, cols int) string {
+	if rows <= 1 && cols <= 1 {
+		return "A1"
+	}
+	if rows == 0 {
+		rows = 1
+	}
+	if cols == 0 {
+		cols = 1
+	}
+	return "A1:" + cellRef(cols-1, rows)
+}
@@ -0,0 +1,58 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"fmt"
+	"strings"
+)
+
+// application is the producer named in docProps/app.xml
+const application = "turbo-export-engine"
+
+// writeDocProps writes the core and extended document properties, which
+// _rels/.rels relates as rId2 and rId3
+func (wb *workbook) writeDocProps(zw *zip.Writer) error {
+	props := wb.props
+
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:dcmitype="http://purl.org/dc/dcmitype/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+`)
+	writeProperty(&sb, "dc:title", props.Title)
+	writeProperty(&sb, "dc:subject", props.Subject)
+	writeProperty(&sb, "dc:creator", props.Creator)
+	writeProperty(&sb, "cp:keywords", props.Keywords)
+	if !props.Created.IsZero() {
+		created := props.Created.UTC().Format("2006-01-02T15:04:05Z")
+		sb.WriteString(fmt.Sprintf("  <dcterms:created xsi:type=\"dcterms:W3CDTF\">%s</dcterms:created>\n", created))
+		sb.WriteString(fmt.Sprintf("  <dcterms:modified xsi:type=\"dcterms:W3CDTF\">%s</dcterms:modified>\n", created))
+	}
+	sb.WriteString(`</cp:coreProperties>`)
+	if err := writeEntry(zw, "docProps/core.xml", sb.String()); err != nil {
+		return err
+	}
+
+	sb.Reset()
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">
+`)
+	writeProperty(&sb, "Application", application)
+	// The sheet names, as listed by file explorers
+	sb.WriteString(fmt.Sprintf(`  <HeadingPairs><vt:vector size="2" baseType="variant"><vt:variant><vt:lpstr>Worksheets</vt:lpstr></vt:variant><vt:variant><vt:i4>%d</vt:i4></vt:variant></vt:vector></HeadingPairs>
+  <TitlesOfParts><vt:vector size="%d" baseType="lpstr">`, len(wb.sheets), len(wb.sheets)))
+	for _, sheet := range wb.sheets {
+		sb.WriteString("<vt:lpstr>" + xmlText(sheet.Name) + "</vt:lpstr>")
+	}
+	sb.WriteString("</vt:vector></TitlesOfParts>\n")
+	writeProperty(&sb, "Company", props.Company)
+	sb.WriteString(`</Properties>`)
+	return writeEntry(zw, "docProps/app.xml", sb.String())
+}
+
+// writeProperty writes element name holding value, unless value is empty
+func writeProperty(sb *strings.Builder, name, value string) {
+	if value == "" {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("  <%s>%s</%s>\n", name, xmlText(value), name))
+}
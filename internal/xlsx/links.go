@@ -0,0 +1,197 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Excel limits on links and formulas
+const (
+	maxHyperlinkLength = 2079
+	maxSheetHyperlinks = 65530
+	maxFormulaLength   = 8192
+)
+
+// Relationship types of worksheet parts
+const (
+	relTable     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/table"
+	relHyperlink = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink"
+)
+
+// hyperlink links the cell at row and col to target, a URL or, when it
+// starts with #, a location in the workbook
+type hyperlink struct {
+	row    int
+	col    int
+	target string
+}
+
+// hyperlinks collects the links of a sheet, in cell order, while its rows
+// are rendered. Links beyond limit are refused and their cells written as
+// plain text.
+type hyperlinks struct {
+	links []hyperlink
+	limit int
+}
+
+func newHyperlinks() *hyperlinks {
+	return &hyperlinks{limit: maxSheetHyperlinks}
+}
+
+// newChunkLinks returns the collector a chunk of rows is rendered with
+// ahead of its turn. It has no limit; merge applies the sheet's.
+func newChunkLinks() *hyperlinks {
+	return &hyperlinks{limit: math.MaxInt}
+}
+
+// add adds link unless the sheet has no room left for it
+func (h *hyperlinks) add(link hyperlink) bool {
+	if len(h.links) >= h.limit {
+		return false
+	}
+	h.links = append(h.links, link)
+	return true
+}
+
+// merge adds the links of local, the collector a chunk was rendered with,
+// and returns the chunk's XML with the cells of the links h has no room for
+// restyled as plain text, plain mapping link styles to text ones. Merging
+// chunks in row order caps the links as rendering the rows in order would.
+func (h *hyperlinks) merge(local *hyperlinks, xml string, plain map[int]int) string {
+	room := h.limit - len(h.links)
+	if len(local.links) <= room {
+		h.links = append(h.links, local.links...)
+		return xml
+	}
+	h.links = append(h.links, local.links[:room]...)
+
+	attr := func(style int) string {
+		if style == 0 {
+			return `"`
+		}
+		return `" s="` + strconv.Itoa(style) + `"`
+	}
+	var restyle []string
+	for _, link := range local.links[room:] {
+		start := `<c r="` + cellRef(link.col, link.row)
+		for from, to := range plain {
+			restyle = append(restyle, start+attr(from), start+attr(to))
+		}
+	}
+	return strings.NewReplacer(restyle...).Replace(xml)
+}
+
+// plainStyles maps the styles of link cells to those of the plain text
+// cells links beyond the sheet's limit are written as
+func (r *renderer) plainStyles() map[int]int {
+	plain := map[int]int{r.linkStyle: 0}
+	if r.quoted != nil {
+		plain[r.quoted[r.linkStyle]] = r.quoted[0]
+	}
+	return plain
+}
+
+// hyperlinkValue returns the target and display text of a hyperlink cell
+// value: a URL, shown as is, or an object with "url" and "text" keys
+func hyperlinkValue(value interface{}) (target, text string) {
+	switch v := value.(type) {
+	case string:
+		return v, v
+	case map[string]interface{}:
+		target, _ = v["url"].(string)
+		text, _ = v["text"].(string)
+		if text == "" {
+			text = target
+		}
+		return target, text
+	}
+	return "", ""
+}
+
+// validTarget reports whether Excel accepts target as a hyperlink
+func validTarget(target string) bool {
+	return target != "" && target != "#" && len(target) <= maxHyperlinkLength && isXMLSafe(target)
+}
+
+// formulaText returns the formula of a formula cell value in row rowNum,
+// reporting false for values that cannot be written as a formula
+func formulaText(value string, rowNum int) (string, bool) {
+	formula := strings.TrimPrefix(strings.TrimSpace(value), "=")
+	formula = strings.ReplaceAll(formula, "{row}", strconv.Itoa(rowNum))
+	if formula == "" || len(formula) > maxFormulaLength || !isXMLSafe(formula) {
+		return "", false
+	}
+	return formula, true
+}
+
+// relationship relates a worksheet to a table or hyperlink target
+type relationship struct {
+	typ      string
+	target   string
+	external bool
+}
+
+// addSheetRel relates the last added sheet to a part or external target
+// and returns the relationship ID
+func (wb *workbook) addSheetRel(rel relationship) string {
+	sheet := len(wb.sheets)
+	wb.rels[sheet] = append(wb.rels[sheet], rel)
+	return fmt.Sprintf("rId%d", len(wb.rels[sheet]))
+}
+
+// writeHyperlinks writes the <hyperlinks> element of the last added sheet,
+// relating each distinct URL once
+func (wb *workbook) writeHyperlinks(sb *strings.Builder, links []hyperlink) {
+	if len(links) == 0 {
+		return
+	}
+
+	ids := make(map[string]string)
+	sb.WriteString("  <hyperlinks>")
+	for _, link := range links {
+		ref := cellRef(link.col, link.row)
+		if location := strings.TrimPrefix(link.target, "#"); location != link.target {
+			sb.WriteString(fmt.Sprintf(`<hyperlink ref="%s" location="%s"/>`, ref, html.EscapeString(location)))
+			continue
+		}
+		id, ok := ids[link.target]
+		if !ok {
+			id = wb.addSheetRel(relationship{typ: relHyperlink, target: link.target, external: true})
+			ids[link.target] = id
+		}
+		sb.WriteString(fmt.Sprintf(`<hyperlink ref="%s" r:id="%s"/>`, ref, id))
+	}
+	sb.WriteString("</hyperlinks>\n")
+}
+
+// writeSheetRels writes the relationships of the sheets that have any
+func (wb *workbook) writeSheetRels(zw *zip.Writer) error {
+	for sheet := 1; sheet <= len(wb.sheets); sheet++ {
+		rels := wb.rels[sheet]
+		if len(rels) == 0 {
+			continue
+		}
+
+		var sb strings.Builder
+		sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+`)
+		for i, rel := range rels {
+			sb.WriteString(fmt.Sprintf(`  <Relationship Id="rId%d" Type="%s" Target="%s"`, i+1, rel.typ, html.EscapeString(rel.target)))
+			if rel.external {
+				sb.WriteString(` TargetMode="External"`)
+			}
+			sb.WriteString("/>\n")
+		}
+		sb.WriteString(`</Relationships>`)
+
+		if err := writeEntry(zw, fmt.Sprintf("xl/worksheets/_rels/sheet%d.xml.rels", sheet), sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// Worksheet XML surrounding the rows. The sheet properties rendered by
+// renderer.sheetHeader go between sheetStart and sheetDataStart, and the
+// elements rendered by workbook.sheetEnd between sheetDataEnd and sheetEnd.
+const (
+	sheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+`
+	sheetDataStart = `  <sheetData>
+`
+	sheetDataEnd = `  </sheetData>
+`
+	sheetEnd = `</worksheet>`
+)
+
+// Characters Excel does not allow in sheet names
+const invalidSheetNameChars = `[]:*?/\`
+
+// maxSheetName is the longest sheet name Excel accepts
+const maxSheetName = 31
+
+// workbook collects the parts of a workbook while its sheets are written.
+// The parts that list the sheets are written once every sheet is known,
+// which the zip format allows.
+type workbook struct {
+	sheets  []types.SheetResult
+	names   map[string]bool
+	styles  *styleSheet
+	shared  *sharedStrings // nil when strings are written inline
+	tables  []table
+	filters map[int]string         // autofilter ranges by 1-based sheet number
+	rels    map[int][]relationship // worksheet relationships by 1-based sheet number
+	props   types.DocumentProperties
+	recalc  bool // formulas are computed when the workbook is opened
+}
+
+func newWorkbook(opts types.XLSXOptions) *workbook {
+	wb := &workbook{
+		names:   make(map[string]bool),
+		styles:  newStyleSheet(),
+		filters: make(map[int]string),
+		rels:    make(map[int][]relationship),
+		props:   opts.Properties,
+	}
+	for _, cellType := range opts.ColumnTypes {
+		if cellType == types.CellFormula {
+			wb.recalc = true
+		}
+	}
+	if opts.SharedStrings {
+		wb.shared = newSharedStrings(opts.SharedStringsLimit)
+	}
+	return wb
+}
+
+// addSheet registers the next sheet and returns the path of its zip entry.
+// An empty name defaults to SheetN.
+func (wb *workbook) addSheet(name string) (string, error) {
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", len(wb.sheets)+1)
+	}
+	if err := checkSheetName(name); err != nil {
+		return "", err
+	}
+
+	// Excel compares sheet names case-insensitively
+	key := strings.ToLower(name)
+	if wb.names[key] {
+		return "", fmt.Errorf("duplicate sheet name %q", name)
+	}
+	wb.names[key] = true
+
+	wb.sheets = append(wb.sheets, types.SheetResult{Name: name})
+	return fmt.Sprintf("xl/worksheets/sheet%d.xml", len(wb.sheets)), nil
+}
+
+// setRows records the number of data rows of the last sheet
+func (wb *workbook) setRows(rows int) {
+	wb.sheets[len(wb.sheets)-1].Rows = rows
+}
+
+func checkSheetName(name string) error {
+	if utf8.RuneCountInString(name) > maxSheetName {
+		return fmt.Errorf("invalid sheet name %q: longer than %d characters", name, maxSheetName)
+	}
+	if strings.ContainsAny(name, invalidSheetNameChars) {
+		return fmt.Errorf("invalid sheet name %q: must not contain any of %s", name, invalidSheetNameChars)
+	}
+	if !isXMLSafe(name) {
+		return fmt.Errorf("invalid sheet name %q: must not contain control characters or invalid UTF-8", name)
+	}
+	if strings.HasPrefix(name, "'") || strings.HasSuffix(name, "'") {
+		return fmt.Errorf("invalid sheet name %q: must not start or end with an apostrophe", name)
+	}
+	return nil
+}
+
+// open writes the parts that precede the sheets. MIME sniffers such as
+// libmagic identify a workbook by _rels/.rels (or [Content_Types].xml)
+// coming first and xl/ parts following it.
+func (wb *workbook) open(zw *zip.Writer) error {
+	return writeRels(zw)
+}
+
+// close writes the parts describing the workbook, after its sheets
+func (wb *workbook) close(zw *zip.Writer) error {
+	if err := wb.writeWorkbookRels(zw); err != nil {
+		return err
+	}
+	if err := wb.writeWorkbook(zw); err != nil {
+		return err
+	}
+	if err := writeStyles(zw, wb.styles); err != nil {
+		return err
+	}
+	if wb.shared != nil {
+		if err := writeSharedStrings(zw, wb.shared); err != nil {
+			return err
+		}
+	}
+	if err := wb.writeTables(zw); err != nil {
+		return err
+	}
+	if err := wb.writeSheetRels(zw); err != nil {
+		return err
+	}
+	if err := wb.writeDocProps(zw); err != nil {
+		return err
+	}
+	return wb.writeContentTypes(zw)
+}
+
+func (wb *workbook) writeContentTypes(zw *zip.Writer) error {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+  <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+  <Default Extension="xml" ContentType="application/xml"/>
+  <Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+  <Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+  <Override PartName="/docProps/app.xml" ContentType="application/vnd.openxmlformats-officedocument.extended-properties+xml"/>
+`)
+	for i := range wb.sheets {
+		sb.WriteString(fmt.Sprintf(`  <Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+`, i+1))
+	}
+	sb.WriteString(`  <Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+`)
+	if wb.shared != nil {
+		sb.WriteString(`  <Override PartName="/xl/sharedStrings.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sharedStrings+xml"/>
+`)
+	}
+	for i := range wb.tables {
+		sb.WriteString(fmt.Sprintf(`  <Override PartName="/xl/tables/table%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.table+xml"/>
+`, i+1))
+	}
+	sb.WriteString(`</Types>`)
+	return writeEntry(zw, "[Content_Types].xml", sb.String())
+}
+
+func writeRels(zw *zip.Writer) error {
+	return writeEntry(zw, "_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+  <Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>
+  <Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties" Target="docProps/app.xml"/>
+</Relationships>`)
+}
+
+// writeWorkbookRels relates the sheets as rId1 to rIdN, followed by the
+// styles and shared strings
+func (wb *workbook) writeWorkbookRels(zw *zip.Writer) error {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+`)
+	for i := range wb.sheets {
+		sb.WriteString(fmt.Sprintf(`  <Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>
+`, i+1, i+1))
+	}
+	id := len(wb.sheets) + 1
+	sb.WriteString(fmt.Sprintf(`  <Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+`, id))
+	if wb.shared != nil {
+		sb.WriteString(fmt.Sprintf(`  <Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/>
+`, id+1))
+	}
+	sb.WriteString(`</Relationships>`)
+	return writeEntry(zw, "xl/_rels/workbook.xml.rels", sb.String())
+}
+
+func (wb *workbook) writeWorkbook(zw *zip.Writer) error {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+  <sheets>
+`)
+	for i, sheet := range wb.sheets {
+		sb.WriteString(fmt.Sprintf(`    <sheet name="%s" sheetId="%d" r:id="rId%d"/>
+`, html.EscapeString(sheet.Name), i+1, i+1))
+	}
+	sb.WriteString("  </sheets>\n")
+
+	if len(wb.filters) > 0 {
+		sb.WriteString("  <definedNames>")
+		for i, sheet := range wb.sheets {
+			if ref, ok := wb.filters[i+1]; ok {
+				sb.WriteString(filterDatabase(i+1, sheet.Name, ref))
+			}
+		}
+		sb.WriteString("</definedNames>\n")
+	}
+
+	// Formula cells carry no cached values
+	if wb.recalc {
+		sb.WriteString("  <calcPr fullCalcOnLoad=\"1\"/>\n")
+	}
+
+	sb.WriteString(`</workbook>`)
+	return writeEntry(zw, "xl/workbook.xml", sb.String())
+}
+
+// writeStyles writes xl/styles.xml. Zip entries may come in any order, so
+// it is written after the sheets.
+func writeStyles(zw *zip.Writer, styles *styleSheet) error {
+	return writeEntry(zw, "xl/styles.xml", styles.xml())
+}
+
+func writeEntry(zw *zip.Writer, name, content string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(content))
+	return err
+}
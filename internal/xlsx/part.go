@@ -0,0 +1,109 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// WritePart writes a complete workbook holding rows to w. It backs the XLSX
+// parts of split exports, so parts are rendered exactly like workbooks from
+// the Builder, including the rollover into further sheets at the row limit.
+// The splitter has fitted the rows to the headers already.
+func WritePart(w io.Writer, headers []string, rows []types.Row, config *types.SplitZipConfig) error {
+	opts := config.XLSX
+	zw := zip.NewWriter(w)
+	wb := newWorkbook(opts)
+	if err := wb.open(zw); err != nil {
+		return err
+	}
+
+	for part := 1; part == 1 || len(rows) > 0; part++ {
+		var headerRow []string
+		if config.IncludeHeaders && (part == 1 || opts.RepeatHeaders) {
+			headerRow = headers
+		}
+
+		n := sheetCapacity(opts, len(headerRow) > 0)
+		if n > len(rows) {
+			n = len(rows)
+		}
+		if err := writePartSheet(zw, wb, headers, headerRow, rows[:n], config); err != nil {
+			return err
+		}
+		rows = rows[n:]
+	}
+
+	if err := wb.close(zw); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to close xlsx writer: %w", err)
+	}
+	return nil
+}
+
+// writePartSheet adds one sheet of a part, starting with headerRow unless
+// it is empty
+func writePartSheet(zw *zip.Writer, wb *workbook, headers, headerRow []string, rows []types.Row, config *types.SplitZipConfig) error {
+	opts := config.XLSX
+	path, err := wb.addSheet("")
+	if err != nil {
+		return err
+	}
+	r, err := newRenderer(headers, opts, valueOptions{config.Injection, config.Missing, config.Numbers}, wb.styles, wb.shared)
+	if err != nil {
+		return err
+	}
+	if opts.AutoWidth {
+		sample := rows
+		if len(sample) > widthSampleRows {
+			sample = sample[:widthSampleRows]
+		}
+		r.estimateWidths(headerRow, sample)
+	}
+
+	sheet, err := zw.Create(path)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriterSize(sheet, 128*1024)
+
+	if _, err := buffered.WriteString(r.sheetHeader(len(headerRow) > 0, sheetDimension(headerRow, rows))); err != nil {
+		return err
+	}
+
+	rowNum := 1
+	if len(headerRow) > 0 {
+		rowXML, err := r.headerRow(rowNum, headerRow)
+		if err != nil {
+			return err
+		}
+		if _, err := buffered.WriteString(rowXML); err != nil {
+			return err
+		}
+		rowNum++
+	}
+
+	rowsXML, err := r.rows(rowNum, rows)
+	if err != nil {
+		return err
+	}
+	if _, err := buffered.WriteString(rowsXML); err != nil {
+		return err
+	}
+	footer := wb.sheetEnd(opts, r, headerRow, len(rows))
+	if _, err := buffered.WriteString(footer); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+
+	wb.setRows(len(rows))
+	return nil
+}
@@ -0,0 +1,63 @@
+package xlsx
+
+import (
+	"fmt"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// MaxSheetRows is the number of rows Excel allows in one worksheet
+const MaxSheetRows = 1048576
+
+// sheetCapacity returns how many data rows fit in one sheet, leaving room
+// for its header row
+func sheetCapacity(opts types.XLSXOptions, headerRow bool) int {
+	limit := opts.MaxRowsPerSheet
+	if limit <= 0 || limit > MaxSheetRows {
+		limit = MaxSheetRows
+	}
+	if headerRow {
+		limit--
+	}
+	// Every sheet takes at least one row, so rolling over always advances
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// rolloverName names the part-th sheet a sheet's rows are spread over:
+// "Orders", "Orders (2)", ... An unnamed sheet leaves the next SheetN name
+// to workbook.addSheet.
+func rolloverName(name string, part int) string {
+	if part == 1 || name == "" {
+		return name
+	}
+	suffix := fmt.Sprintf(" (%d)", part)
+	base := []rune(name)
+	if max := maxSheetName - len(suffix); len(base) > max {
+		base = base[:max]
+	}
+	return string(base) + suffix
+}
+
+// sheetRows yields at most max rows of its source, the rows of one sheet
+type sheetRows struct {
+	types.RowSource
+	max   int
+	count int
+}
+
+func (s *sheetRows) Next() bool {
+	if s.count >= s.max || !s.RowSource.Next() {
+		return false
+	}
+	s.count++
+	return true
+}
+
+// full reports whether the sheet reached its capacity, in which case more
+// rows may follow in the source
+func (s *sheetRows) full() bool {
+	return s.count >= s.max
+}
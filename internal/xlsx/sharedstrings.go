@@ -0,0 +1,154 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultSharedStringsLimit caps the memory held by a shared strings table
+// when XLSXOptions.SharedStringsLimit is unset
+const DefaultSharedStringsLimit = 64 << 20
+
+// sharedStringOverhead estimates the bytes a table entry costs beyond the
+// string itself (map entry, slice header)
+const sharedStringOverhead = 64
+
+// sharedStringMarker precedes the index of a shared string cell. Cell text
+// is escaped, so it appears nowhere else in sheet XML.
+const sharedStringMarker = `" t="s"><v>`
+
+// sharedStrings is the deduplicated string table of xl/sharedStrings.xml.
+// Once the table reaches its memory limit, new strings are left to be
+// written inline while strings already in the table keep being shared.
+// It is safe for concurrent use by chunk renderers.
+type sharedStrings struct {
+	mu      sync.Mutex
+	index   map[string]int
+	strings []string
+	refs    int
+	size    int64
+	limit   int64
+}
+
+func newSharedStrings(limit int64) *sharedStrings {
+	if limit <= 0 {
+		limit = DefaultSharedStringsLimit
+	}
+	return &sharedStrings{
+		index: make(map[string]int),
+		limit: limit,
+	}
+}
+
+// newChunkStrings returns the table a chunk of rows is rendered with ahead
+// of its turn. It has no limit; merge applies the workbook's.
+func newChunkStrings() *sharedStrings {
+	return newSharedStrings(math.MaxInt64 / 2)
+}
+
+// add returns the table index of s, adding it if there is room. It reports
+// false when s must be written inline.
+func (t *sharedStrings) add(s string) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	idx := t.insert(s)
+	if idx < 0 {
+		return 0, false
+	}
+	t.refs++
+	return idx, true
+}
+
+// insert returns the table index of s, adding it if there is room, or -1.
+// The caller holds mu.
+func (t *sharedStrings) insert(s string) int {
+	if idx, ok := t.index[s]; ok {
+		return idx
+	}
+
+	size := int64(len(s)) + sharedStringOverhead
+	if t.size+size > t.limit {
+		return -1
+	}
+
+	idx := len(t.strings)
+	t.strings = append(t.strings, s)
+	t.index[s] = idx
+	t.size += size
+	return idx
+}
+
+// merge adds the strings of local, the table a chunk was rendered with, to
+// t in their order and returns the chunk's XML with its shared string cells
+// pointing into t. Cells whose string t has no room for are written inline.
+// Merging chunks in row order numbers the strings as rendering the rows in
+// order would.
+func (t *sharedStrings) merge(local *sharedStrings, xml string) string {
+	if len(local.strings) == 0 {
+		return xml
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	indices := make([]int, len(local.strings))
+	for i, s := range local.strings {
+		indices[i] = t.insert(s)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(xml))
+	for {
+		i := strings.Index(xml, sharedStringMarker)
+		if i < 0 {
+			break
+		}
+		sb.WriteString(xml[:i])
+		xml = xml[i+len(sharedStringMarker):]
+		end := strings.IndexByte(xml, '<')
+		localIdx, _ := strconv.Atoi(xml[:end])
+		xml = xml[end:]
+
+		if idx := indices[localIdx]; idx >= 0 {
+			sb.WriteString(sharedStringMarker)
+			sb.WriteString(strconv.Itoa(idx))
+			t.refs++
+			continue
+		}
+		sb.WriteString(`" t="inlineStr"><is><t>`)
+		sb.WriteString(xmlText(local.strings[localIdx]))
+		sb.WriteString(`</t></is>`)
+		xml = strings.TrimPrefix(xml, "</v>")
+	}
+	sb.WriteString(xml)
+	return sb.String()
+}
+
+// writeSharedStrings writes xl/sharedStrings.xml. Like the styles, it is
+// complete only after every sheet has been rendered.
+func writeSharedStrings(zw *zip.Writer, t *sharedStrings) error {
+	w, err := zw.Create("xl/sharedStrings.xml")
+	if err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriterSize(w, 128*1024)
+	fmt.Fprintf(buffered, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="%d" uniqueCount="%d">
+`, t.refs, len(t.strings))
+	for _, s := range t.strings {
+		buffered.WriteString("  <si><t>")
+		buffered.WriteString(xmlText(s))
+		buffered.WriteString("</t></si>\n")
+	}
+	buffered.WriteString("</sst>")
+
+	// bufio.Writer keeps the first write error and returns it here
+	return buffered.Flush()
+}
@@ -0,0 +1,227 @@
+package xlsx
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// firstCustomNumFmt is the first number format ID free for custom formats;
+// lower IDs are built into Excel
+const firstCustomNumFmt = 164
+
+// firstCustomFill is the first fill ID free for custom fills; the first two
+// fills are reserved by Excel
+const firstCustomFill = 2
+
+// Number formats applied to date cells
+const (
+	dateFormat     = "yyyy-mm-dd"
+	dateTimeFormat = "yyyy-mm-dd hh:mm:ss"
+)
+
+// builtinNumFmts are the built-in number formats that need no numFmt entry
+var builtinNumFmts = map[string]int{
+	"0":        1,
+	"0.00":     2,
+	"#,##0":    3,
+	"#,##0.00": 4,
+	"0%":       9,
+	"0.00%":    10,
+}
+
+// namedNumFmts are the number format names accepted in place of a format
+// code
+var namedNumFmts = map[string]string{
+	"currency":  `"$"#,##0.00`,
+	"percent":   "0.00%",
+	"date":      dateFormat,
+	"datetime":  dateTimeFormat,
+	"thousands": "#,##0",
+	"decimal":   "#,##0.00",
+}
+
+// numFmtCode resolves a number format name to its format code; anything
+// else is taken as a format code already
+func numFmtCode(format string) string {
+	if code, ok := namedNumFmts[strings.ToLower(format)]; ok {
+		return code
+	}
+	return format
+}
+
+// fillColor normalises an RGB hex color such as "#DDEBF7" to the ARGB form
+// of styles.xml
+func fillColor(color string) (string, error) {
+	rgb := strings.TrimPrefix(color, "#")
+	if len(rgb) != 6 {
+		return "", fmt.Errorf("invalid fill color %q: expected RRGGBB", color)
+	}
+	for _, c := range rgb {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return "", fmt.Errorf("invalid fill color %q: expected RRGGBB", color)
+		}
+	}
+	return "FF" + strings.ToUpper(rgb), nil
+}
+
+// font is one font of the fonts table
+type font struct {
+	bold bool
+	link bool // underlined in the hyperlink color
+}
+
+// cellStyle is one cell format (an xf entry of cellXfs)
+type cellStyle struct {
+	numFmtID    int
+	fontID      int
+	fillID      int
+	quotePrefix bool // text is shown as entered, never evaluated
+}
+
+// styleSheet collects the cell formats of a workbook and renders
+// xl/styles.xml. Every format is registered before rows are rendered, so
+// renderers can share it across goroutines.
+type styleSheet struct {
+	numFmts   []string
+	numFmtIDs map[string]int
+	fonts     []font
+	fontIDs   map[font]int
+	fills     []string
+	fillIDs   map[string]int
+	styles    []cellStyle
+	index     map[cellStyle]int
+}
+
+func newStyleSheet() *styleSheet {
+	s := &styleSheet{
+		numFmtIDs: make(map[string]int),
+		fontIDs:   make(map[font]int),
+		fillIDs:   make(map[string]int),
+		index:     make(map[cellStyle]int),
+	}
+	// Index 0 is the default font and format of unstyled cells
+	s.font(font{})
+	s.add(cellStyle{})
+	return s
+}
+
+// numFmt registers a number format code and returns its ID
+func (s *styleSheet) numFmt(code string) int {
+	if id, ok := builtinNumFmts[code]; ok {
+		return id
+	}
+	if id, ok := s.numFmtIDs[code]; ok {
+		return id
+	}
+	id := firstCustomNumFmt + len(s.numFmts)
+	s.numFmts = append(s.numFmts, code)
+	s.numFmtIDs[code] = id
+	return id
+}
+
+// font registers a font and returns its ID
+func (s *styleSheet) font(f font) int {
+	if id, ok := s.fontIDs[f]; ok {
+		return id
+	}
+	id := len(s.fonts)
+	s.fonts = append(s.fonts, f)
+	s.fontIDs[f] = id
+	return id
+}
+
+// fill registers a solid fill of an ARGB color and returns its ID
+func (s *styleSheet) fill(argb string) int {
+	if id, ok := s.fillIDs[argb]; ok {
+		return id
+	}
+	id := firstCustomFill + len(s.fills)
+	s.fills = append(s.fills, argb)
+	s.fillIDs[argb] = id
+	return id
+}
+
+// add registers a cell format and returns its index for the s attribute
+func (s *styleSheet) add(style cellStyle) int {
+	if idx, ok := s.index[style]; ok {
+		return idx
+	}
+	idx := len(s.styles)
+	s.styles = append(s.styles, style)
+	s.index[style] = idx
+	return idx
+}
+
+// quoted registers the quote-prefixed variant of the cell format at idx and
+// returns its index
+func (s *styleSheet) quoted(idx int) int {
+	style := s.styles[idx]
+	style.quotePrefix = true
+	return s.add(style)
+}
+
+func (s *styleSheet) xml() string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+`)
+
+	if len(s.numFmts) > 0 {
+		sb.WriteString(fmt.Sprintf("  <numFmts count=\"%d\">", len(s.numFmts)))
+		for i, code := range s.numFmts {
+			sb.WriteString(fmt.Sprintf("<numFmt numFmtId=\"%d\" formatCode=\"%s\"/>",
+				firstCustomNumFmt+i, html.EscapeString(code)))
+		}
+		sb.WriteString("</numFmts>\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("  <fonts count=\"%d\">", len(s.fonts)))
+	for _, f := range s.fonts {
+		sb.WriteString("<font>")
+		if f.bold {
+			sb.WriteString("<b/>")
+		}
+		if f.link {
+			sb.WriteString(`<u/><sz val="11"/><color rgb="FF0563C1"/><name val="Calibri"/></font>`)
+			continue
+		}
+		sb.WriteString(`<sz val="11"/><name val="Calibri"/></font>`)
+	}
+	sb.WriteString("</fonts>\n")
+
+	sb.WriteString(fmt.Sprintf("  <fills count=\"%d\">", firstCustomFill+len(s.fills)))
+	sb.WriteString(`<fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>`)
+	for _, argb := range s.fills {
+		sb.WriteString(fmt.Sprintf(`<fill><patternFill patternType="solid"><fgColor rgb="%s"/><bgColor indexed="64"/></patternFill></fill>`, argb))
+	}
+	sb.WriteString("</fills>\n")
+
+	sb.WriteString(`  <borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+  <cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+`)
+
+	sb.WriteString(fmt.Sprintf("  <cellXfs count=\"%d\">", len(s.styles)))
+	for _, style := range s.styles {
+		sb.WriteString(fmt.Sprintf("<xf numFmtId=\"%d\" fontId=\"%d\" fillId=\"%d\" borderId=\"0\" xfId=\"0\"",
+			style.numFmtID, style.fontID, style.fillID))
+		if style.numFmtID != 0 {
+			sb.WriteString(` applyNumberFormat="1"`)
+		}
+		if style.fontID != 0 {
+			sb.WriteString(` applyFont="1"`)
+		}
+		if style.fillID != 0 {
+			sb.WriteString(` applyFill="1"`)
+		}
+		if style.quotePrefix {
+			sb.WriteString(` quotePrefix="1"`)
+		}
+		sb.WriteString("/>")
+	}
+	sb.WriteString("</cellXfs>\n")
+
+	sb.WriteString(`  <cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>
+</styleSheet>`)
+	return sb.String()
+}
@@ -0,0 +1,161 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// DefaultTableStyle is the table style used when XLSXOptions.TableStyle is
+// unset
+const DefaultTableStyle = "TableStyleMedium2"
+
+var (
+	// tableNamePattern matches the names Excel accepts for tables
+	tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+	// cellRefPattern matches names Excel would read as cell references
+	cellRefPattern = regexp.MustCompile(`^([A-Za-z]{1,3}[0-9]+|[RrCc]|[Rr][0-9]*[Cc][0-9]*)$`)
+)
+
+// table is an Excel table (ListObject) over the rows of one sheet
+type table struct {
+	name    string
+	ref     string
+	columns []string
+	style   string
+}
+
+// sheetEnd renders the elements following the rows of the last added
+// sheet, rendered by r: its autofilter, hyperlinks and table part over the
+// header row headers and dataRows rows. Tables and filters need a header
+// row, so sheets without one get neither.
+func (wb *workbook) sheetEnd(opts types.XLSXOptions, r *renderer, headers []string, dataRows int) string {
+	var sb strings.Builder
+	sb.WriteString(sheetDataEnd)
+
+	var tableID string
+	if len(headers) > 0 {
+		lastCol := columnName(len(headers) - 1)
+		switch {
+		case opts.Table:
+			// A table spans at least one data row, even an empty one
+			lastRow := 1 + dataRows
+			if dataRows == 0 {
+				lastRow = 2
+			}
+			tableID = wb.addTable(opts, r.tableColumns, fmt.Sprintf("A1:%s%d", lastCol, lastRow))
+		case opts.AutoFilter:
+			ref := fmt.Sprintf("A1:%s%d", lastCol, 1+dataRows)
+			wb.filters[len(wb.sheets)] = ref
+			sb.WriteString(fmt.Sprintf("  <autoFilter ref=\"%s\"/>\n", ref))
+		}
+	}
+
+	wb.writeHyperlinks(&sb, r.links.links)
+
+	if tableID != "" {
+		sb.WriteString(fmt.Sprintf("  <tableParts count=\"1\"><tablePart r:id=\"%s\"/></tableParts>\n", tableID))
+	}
+
+	sb.WriteString(sheetEnd)
+	return sb.String()
+}
+
+// checkTable reports whether a table can be built over the column names
+// columns before any row is written
+func checkTable(opts types.XLSXOptions, columns []string) error {
+	if opts.TableName != "" {
+		if err := checkTableName(opts.TableName); err != nil {
+			return err
+		}
+	}
+
+	// Table column names must be unique, non-empty and match the header
+	// cells exactly, so they cannot be renamed
+	seen := make(map[string]bool, len(columns))
+	for _, name := range columns {
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			return fmt.Errorf("tables require unique, non-empty headers, got %q", name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// addTable registers the table of the last added sheet over the column
+// names columns and returns the ID of its relationship. Its name is
+// TableName or "Table", suffixed with the table number unless it is the
+// first table with a given TableName.
+func (wb *workbook) addTable(opts types.XLSXOptions, columns []string, ref string) string {
+	name := opts.TableName
+	if name == "" {
+		name = "Table"
+	}
+	if len(wb.tables) > 0 || opts.TableName == "" {
+		name = fmt.Sprintf("%s%d", name, len(wb.tables)+1)
+	}
+
+	style := opts.TableStyle
+	if style == "" {
+		style = DefaultTableStyle
+	}
+
+	wb.tables = append(wb.tables, table{
+		name:    name,
+		ref:     ref,
+		columns: columns,
+		style:   style,
+	})
+	return wb.addSheetRel(relationship{typ: relTable, target: fmt.Sprintf("../tables/table%d.xml", len(wb.tables))})
+}
+
+func checkTableName(name string) error {
+	if len(name) > 255 || !tableNamePattern.MatchString(name) || cellRefPattern.MatchString(name) {
+		return fmt.Errorf("invalid table name %q: must start with a letter or underscore, contain only letters, digits, underscores and periods, and not look like a cell reference", name)
+	}
+	return nil
+}
+
+// writeTables writes the table parts
+func (wb *workbook) writeTables(zw *zip.Writer) error {
+	for i, t := range wb.tables {
+		id := i + 1
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<table xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" id="%d" name="%s" displayName="%s" ref="%s" totalsRowShown="0">
+  <autoFilter ref="%s"/>
+`, id, t.name, t.name, t.ref, t.ref))
+		sb.WriteString(fmt.Sprintf("  <tableColumns count=\"%d\">", len(t.columns)))
+		for col, name := range t.columns {
+			sb.WriteString(fmt.Sprintf("<tableColumn id=\"%d\" name=\"%s\"/>", col+1, xmlText(name)))
+		}
+		sb.WriteString("</tableColumns>\n")
+		sb.WriteString(fmt.Sprintf(`  <tableStyleInfo name="%s" showFirstColumn="0" showLastColumn="0" showRowStripes="1" showColumnStripes="0"/>
+</table>`, html.EscapeString(t.style)))
+
+		if err := writeEntry(zw, fmt.Sprintf("xl/tables/table%d.xml", id), sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterDatabase renders the hidden defined name Excel keeps for the
+// autofilter of sheet number sheet
+func filterDatabase(sheet int, name, ref string) string {
+	parts := strings.SplitN(ref, ":", 2)
+	absolute := make([]string, len(parts))
+	for i, cell := range parts {
+		split := strings.IndexAny(cell, "0123456789")
+		absolute[i] = "$" + cell[:split] + "$" + cell[split:]
+	}
+	quoted := "'" + strings.ReplaceAll(name, "'", "''") + "'"
+	return fmt.Sprintf(`<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">%s!%s</definedName>`,
+		sheet-1, html.EscapeString(quoted), strings.Join(absolute, ":"))
+}
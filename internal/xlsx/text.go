@@ -0,0 +1,180 @@
+package xlsx
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// MaxCellText is the number of characters an Excel cell holds, counted in
+// UTF-16 code units as Excel does
+const MaxCellText = 32767
+
+// escapePattern matches text Excel decodes as an _xHHHH_ character escape
+var escapePattern = regexp.MustCompile(`_x[0-9A-Fa-f]{4}_`)
+
+// textPolicy sanitizes the text of cells
+type textPolicy struct {
+	control  types.ControlCharPolicy
+	longText types.LongTextPolicy
+}
+
+// newTextPolicy validates the text policies of opts, defaulting to
+// stripping control characters and truncating long text
+func newTextPolicy(opts types.XLSXOptions) (textPolicy, error) {
+	p := textPolicy{control: opts.ControlChars, longText: opts.LongText}
+
+	switch p.control {
+	case "":
+		p.control = types.ControlCharsStrip
+	case types.ControlCharsStrip, types.ControlCharsReplace, types.ControlCharsEncode:
+	default:
+		return p, fmt.Errorf("invalid control character policy %q: expected strip, replace or encode", p.control)
+	}
+
+	switch p.longText {
+	case "":
+		p.longText = types.LongTextTruncate
+	case types.LongTextTruncate, types.LongTextError:
+	default:
+		return p, fmt.Errorf("invalid long text policy %q: expected truncate or error", p.longText)
+	}
+	return p, nil
+}
+
+// cell returns value as the cell should hold it: valid UTF-8, without the
+// characters XML cannot represent unless they are to be encoded, and
+// within MaxCellText
+func (p textPolicy) cell(value string) (string, error) {
+	if !isXMLSafe(value) {
+		value = p.sanitize(value)
+	}
+
+	// UTF-8 never takes fewer bytes than UTF-16 code units, so short
+	// strings need no counting
+	if len(value) > MaxCellText {
+		if n := utf16Len(value); n > MaxCellText {
+			if p.longText == types.LongTextError {
+				return "", fmt.Errorf("text of %d characters exceeds Excel's cell limit of %d", n, MaxCellText)
+			}
+			value = truncateUTF16(value, MaxCellText)
+		}
+	}
+	return value, nil
+}
+
+// sanitize applies the control character policy to s. Encoded characters
+// are kept as they are and escaped by xmlText.
+func (p textPolicy) sanitize(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i, r := range s {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				// Invalid UTF-8 cannot be encoded, only replaced
+				if p.control != types.ControlCharsStrip {
+					sb.WriteRune(utf8.RuneError)
+				}
+				continue
+			}
+		}
+		if isXMLChar(r) {
+			sb.WriteRune(r)
+			continue
+		}
+		switch p.control {
+		case types.ControlCharsReplace:
+			sb.WriteRune(utf8.RuneError)
+		case types.ControlCharsEncode:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// xmlText escapes s for an XML element. Characters XML cannot represent
+// are written as _xHHHH_ escapes, and literal text looking like an escape
+// is protected with _x005F_ so Excel shows it unchanged.
+func xmlText(s string) string {
+	if strings.Contains(s, "_x") {
+		s = escapePattern.ReplaceAllString(s, "_x005F$0")
+	}
+	escaped := html.EscapeString(s)
+	if isXMLSafe(escaped) {
+		return escaped
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(escaped))
+	for _, r := range escaped {
+		if isXMLChar(r) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteString(fmt.Sprintf("_x%04X_", r))
+		}
+	}
+	return sb.String()
+}
+
+// isXMLChar reports whether XML 1.0 can represent r
+func isXMLChar(r rune) bool {
+	switch {
+	case r == '\t', r == '\n', r == '\r':
+		return true
+	case r < 0x20:
+		return false
+	case r == 0xFFFE, r == 0xFFFF:
+		return false
+	}
+	return true
+}
+
+// isXMLSafe reports whether s is valid UTF-8 made only of characters XML
+// can represent
+func isXMLSafe(s string) bool {
+	for i, r := range s {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				return false
+			}
+		}
+		if !isXMLChar(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// utf16Len returns the length of s in UTF-16 code units
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r > 0xFFFF {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// truncateUTF16 cuts s to at most max UTF-16 code units without splitting
+// a character
+func truncateUTF16(s string, max int) string {
+	n := 0
+	for i, r := range s {
+		units := 1
+		if r > 0xFFFF {
+			units = 2
+		}
+		if n+units > max {
+			return s[:i]
+		}
+		n += units
+	}
+	return s
+}
@@ -0,0 +1,1267 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// readZip returns the contents of every file in a ZIP archive by name
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+// build exports rows as a workbook in the given mode and returns its files
+func build(t *testing.T, mode types.ExportMode, opts types.XLSXOptions, headers []string, rows []types.Row) map[string]string {
+	t.Helper()
+	files, err := buildProtected(t, mode, opts, types.InjectionOptions{}, headers, rows)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	return files
+}
+
+// buildProtected is build with injection protection, returning build errors
+func buildProtected(t *testing.T, mode types.ExportMode, opts types.XLSXOptions, protect types.InjectionOptions, headers []string, rows []types.Row) (map[string]string, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	config := &types.ExportConfig{
+		Mode:      mode,
+		Format:    types.FormatXLSX,
+		Workers:   3,
+		ChunkSize: 7,
+		Output:    &buf,
+		XLSX:      opts,
+		Injection: protect,
+	}
+	if err := NewBuilder(config).Build(context.Background(), headers, types.NewSliceSource(rows)); err != nil {
+		return nil, err
+	}
+	return readZip(t, buf.Bytes()), nil
+}
+
+var cellPattern = regexp.MustCompile(`(?s)<c r="([A-Z]+[0-9]+)"[^>]*?(?:/>|>.*?</c>)`)
+
+// cells returns the XML of every cell of a worksheet by reference
+func cells(sheet string) map[string]string {
+	found := make(map[string]string)
+	for _, m := range cellPattern.FindAllStringSubmatch(sheet, -1) {
+		found[m[1]] = m[0]
+	}
+	return found
+}
+
+func TestTypedCells(t *testing.T) {
+	declared := func(cellType types.CellType) types.XLSXOptions {
+		return types.XLSXOptions{ColumnTypes: map[string]types.CellType{"v": cellType}}
+	}
+	tests := []struct {
+		name  string
+		opts  types.XLSXOptions
+		value interface{}
+		want  string
+	}{
+		{"float", types.XLSXOptions{}, 1.5, `<c r="A2"><v>1.5</v></c>`},
+		{"large float without exponent", types.XLSXOptions{}, 1e21, `<c r="A2"><v>1000000000000000000000</v></c>`},
+		{"int", types.XLSXOptions{}, -7, `<c r="A2"><v>-7</v></c>`},
+		{"bool", types.XLSXOptions{}, true, `<c r="A2" t="b"><v>1</v></c>`},
+		{"numeric string stays text", types.XLSXOptions{}, "42", `<c r="A2" t="inlineStr"><is><t>42</t></is></c>`},
+		{"NaN is text", types.XLSXOptions{}, math.NaN(), `<c r="A2" t="inlineStr"><is><t>NaN</t></is></c>`},
+		{"date string without detection", types.XLSXOptions{}, "2024-01-02", `<c r="A2" t="inlineStr"><is><t>2024-01-02</t></is></c>`},
+		{"detected date", types.XLSXOptions{DetectDates: true}, "2024-01-02", `<c r="A2" s="1"><v>45293</v></c>`},
+		{"detected date time", types.XLSXOptions{DetectDates: true}, "2024-01-02T12:00:00Z", `<c r="A2" s="2"><v>45293.5</v></c>`},
+		{"time value", types.XLSXOptions{}, time.Date(2024, 1, 2, 6, 0, 0, 0, time.UTC), `<c r="A2" s="2"><v>45293.25</v></c>`},
+		{"date before 1900 is text", types.XLSXOptions{DetectDates: true}, "1899-12-31", `<c r="A2" t="inlineStr"><is><t>1899-12-31</t></is></c>`},
+		{"declared number from string", declared(types.CellNumber), " 42.5 ", `<c r="A2"><v>42.5</v></c>`},
+		{"declared number falls back to text", declared(types.CellNumber), "n/a", `<c r="A2" t="inlineStr"><is><t>n/a</t></is></c>`},
+		{"declared bool from string", declared(types.CellBool), "false", `<c r="A2" t="b"><v>0</v></c>`},
+		{"declared string keeps numbers as text", declared(types.CellString), 3.0, `<c r="A2" t="inlineStr"><is><t>3</t></is></c>`},
+		{"declared date", declared(types.CellDate), "2024-01-02", `<c r="A2" s="1"><v>45293</v></c>`},
+		{"declared date from serial", declared(types.CellDate), 45293.0, `<c r="A2" s="1"><v>45293</v></c>`},
+		{"declared datetime", declared(types.CellDateTime), "2024-01-02", `<c r="A2" s="2"><v>45293</v></c>`},
+		{"escaped text", types.XLSXOptions{}, `a<b & "c"`, `<c r="A2" t="inlineStr"><is><t>a&lt;b &amp; &#34;c&#34;</t></is></c>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := build(t, types.ModeSync, tt.opts, []string{"v"}, []types.Row{{tt.value}})
+			if got := cells(files["xl/worksheets/sheet1.xml"])["A2"]; got != tt.want {
+				t.Fatalf("cell = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateStylesRegistered(t *testing.T) {
+	files := build(t, types.ModeSync, types.XLSXOptions{}, []string{"v"}, []types.Row{{1}})
+	styles := files["xl/styles.xml"]
+	for _, want := range []string{`formatCode="yyyy-mm-dd"`, `formatCode="yyyy-mm-dd hh:mm:ss"`, `<cellXfs count="3">`} {
+		if !strings.Contains(styles, want) {
+			t.Fatalf("styles.xml does not contain %s:\n%s", want, styles)
+		}
+	}
+}
+
+func TestParallelCellsMatchSync(t *testing.T) {
+	headers := []string{"id", "name", "ok"}
+	rows := make([]types.Row, 50)
+	for i := range rows {
+		rows[i] = types.Row{i, "row", i%2 == 0}
+	}
+	sync := build(t, types.ModeSync, types.XLSXOptions{}, headers, rows)
+	parallel := build(t, types.ModeParallel, types.XLSXOptions{}, headers, rows)
+	if sync["xl/worksheets/sheet1.xml"] != parallel["xl/worksheets/sheet1.xml"] {
+		t.Fatal("parallel sheet differs from the sync sheet")
+	}
+	if got := len(cells(sync["xl/worksheets/sheet1.xml"])); got != 3*51 {
+		t.Fatalf("sheet has %d cells, want %d", got, 3*51)
+	}
+}
+
+// cityRows returns rows whose text repeats, starting at a different city
+// in every chunk of the parallel build
+func cityRows(n int) []types.Row {
+	rows := make([]types.Row, n)
+	for i := range rows {
+		rows[i] = types.Row{i, fmt.Sprintf("city %d", (i*7)%11), fmt.Sprintf("unique %d", i)}
+	}
+	return rows
+}
+
+func TestSharedStringsIdenticalInEveryMode(t *testing.T) {
+	headers := []string{"id", "city", "note"}
+	tests := []struct {
+		name string
+		opts types.XLSXOptions
+	}{
+		{"unlimited", types.XLSXOptions{SharedStrings: true}},
+		// Room for a few strings only, so the rest are written inline
+		{"limit reached", types.XLSXOptions{SharedStrings: true, SharedStringsLimit: 600}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := cityRows(200)
+			sync := build(t, types.ModeSync, tt.opts, headers, rows)
+			for i := 0; i < 5; i++ {
+				parallel := build(t, types.ModeParallel, tt.opts, headers, rows)
+				for _, name := range []string{"xl/sharedStrings.xml", "xl/worksheets/sheet1.xml"} {
+					if parallel[name] != sync[name] {
+						t.Fatalf("parallel %s differs from the sync one", name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSharedStringsInRowOrder(t *testing.T) {
+	rows := []types.Row{{"b"}, {"a"}, {"b"}, {"c"}}
+	files := build(t, types.ModeParallel, types.XLSXOptions{SharedStrings: true}, []string{"h"}, rows)
+
+	want := `count="5" uniqueCount="4">
+  <si><t>h</t></si>
+  <si><t>b</t></si>
+  <si><t>a</t></si>
+  <si><t>c</t></si>
+</sst>`
+	if got := files["xl/sharedStrings.xml"]; !strings.HasSuffix(got, want) {
+		t.Fatalf("sharedStrings.xml = %s, want it to end with %s", got, want)
+	}
+	got := cells(files["xl/worksheets/sheet1.xml"])
+	for ref, idx := range map[string]string{"A1": "0", "A2": "1", "A3": "2", "A4": "1", "A5": "3"} {
+		if want := `<c r="` + ref + `" t="s"><v>` + idx + `</v></c>`; got[ref] != want {
+			t.Errorf("cell %s = %s, want %s", ref, got[ref], want)
+		}
+	}
+	if !strings.Contains(files["[Content_Types].xml"], `PartName="/xl/sharedStrings.xml"`) ||
+		!strings.Contains(files["xl/_rels/workbook.xml.rels"], `Target="sharedStrings.xml"`) {
+		t.Fatal("shared strings part is not registered in the package")
+	}
+}
+
+func TestSharedStringsLimitWritesInline(t *testing.T) {
+	// Only "h" and "a" fit under the limit
+	opts := types.XLSXOptions{SharedStrings: true, SharedStringsLimit: 2 * (1 + sharedStringOverhead)}
+	files := build(t, types.ModeParallel, opts, []string{"h"}, []types.Row{{"a"}, {"b"}, {"a"}})
+
+	if got := files["xl/sharedStrings.xml"]; !strings.Contains(got, `count="3" uniqueCount="2"`) {
+		t.Fatalf("sharedStrings.xml = %s, want 2 strings referenced 3 times", got)
+	}
+	got := cells(files["xl/worksheets/sheet1.xml"])
+	if want := `<c r="A3" t="inlineStr"><is><t>b</t></is></c>`; got["A3"] != want {
+		t.Fatalf("cell A3 = %s, want %s", got["A3"], want)
+	}
+	if want := `<c r="A4" t="s"><v>1</v></c>`; got["A4"] != want {
+		t.Fatalf("cell A4 = %s, want %s", got["A4"], want)
+	}
+}
+
+// containsAll fails the test unless content contains every one of want
+func containsAll(t *testing.T, name, content string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(content, w) {
+			t.Fatalf("%s does not contain %s:\n%s", name, w, content)
+		}
+	}
+}
+
+func TestHeaderStyleAndNumberFormats(t *testing.T) {
+	opts := types.XLSXOptions{
+		BoldHeaders:   true,
+		HeaderFill:    "#ddebf7",
+		NumberFormats: map[string]string{"amount": "currency", "pct": "0.00%", "code": "0.000"},
+	}
+	headers := []string{"name", "amount", "pct", "code"}
+	files := build(t, types.ModeSync, opts, headers, []types.Row{{"x", 1234.5, 0.25, 7}})
+
+	containsAll(t, "styles.xml", files["xl/styles.xml"],
+		`<font><b/><sz val="11"/><name val="Calibri"/></font>`,
+		`<fgColor rgb="FFDDEBF7"/>`,
+		`<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/>`,
+		`<numFmt numFmtId="166" formatCode="&#34;$&#34;#,##0.00"/>`,
+		`<numFmt numFmtId="167" formatCode="0.000"/>`,
+		// Built-in formats need no numFmt entry
+		`<xf numFmtId="10" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>`,
+	)
+
+	got := cells(files["xl/worksheets/sheet1.xml"])
+	want := map[string]string{
+		"A1": `<c r="A1" s="3" t="inlineStr"><is><t>name</t></is></c>`,
+		"D1": `<c r="D1" s="3" t="inlineStr"><is><t>code</t></is></c>`,
+		"A2": `<c r="A2" t="inlineStr"><is><t>x</t></is></c>`,
+		"B2": `<c r="B2" s="4"><v>1234.5</v></c>`,
+		"C2": `<c r="C2" s="5"><v>0.25</v></c>`,
+		"D2": `<c r="D2" s="6"><v>7</v></c>`,
+	}
+	for ref, cell := range want {
+		if got[ref] != cell {
+			t.Errorf("cell %s = %s, want %s", ref, got[ref], cell)
+		}
+	}
+}
+
+func TestColumnWidths(t *testing.T) {
+	tests := []struct {
+		name string
+		opts types.XLSXOptions
+		want string
+	}{
+		{"default", types.XLSXOptions{}, `<cols><col min="1" max="3" width="9.140625"/></cols>`},
+		{
+			name: "fixed",
+			opts: types.XLSXOptions{ColumnWidths: map[string]float64{"amount": 12.5}},
+			want: `<cols><col min="1" max="1" width="9.140625"/>` +
+				`<col min="2" max="2" width="12.5" customWidth="1"/>` +
+				`<col min="3" max="3" width="9.140625"/></cols>`,
+		},
+		{
+			name: "fixed and estimated",
+			opts: types.XLSXOptions{
+				ColumnWidths:  map[string]float64{"name": 30.5},
+				NumberFormats: map[string]string{"amount": "currency"},
+				AutoWidth:     true,
+			},
+			// "1234.5" widened for separators, "7" raised to the minimum
+			want: `<cols><col min="1" max="1" width="30.5" customWidth="1"/>` +
+				`<col min="2" max="2" width="11" customWidth="1"/>` +
+				`<col min="3" max="3" width="8" customWidth="1"/></cols>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := build(t, types.ModeSync, tt.opts, []string{"name", "amount", "code"}, []types.Row{{"x", 1234.5, 7}})
+			sheet := files["xl/worksheets/sheet1.xml"]
+			containsAll(t, "sheet1.xml", sheet, tt.want)
+			if strings.Index(sheet, "<cols>") > strings.Index(sheet, "<sheetData>") {
+				t.Fatal("<cols> written after <sheetData>")
+			}
+		})
+	}
+}
+
+func TestInvalidStyleOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts types.XLSXOptions
+		want string
+	}{
+		{"fill color", types.XLSXOptions{HeaderFill: "blue"}, `invalid fill color "blue"`},
+		{"fill digits", types.XLSXOptions{HeaderFill: "GGGGGG"}, `invalid fill color "GGGGGG"`},
+		{"zero width", types.XLSXOptions{ColumnWidths: map[string]float64{"a": 0}}, `invalid width 0 for column "a"`},
+		{"wide column", types.XLSXOptions{ColumnWidths: map[string]float64{"a": 256}}, `invalid width 256 for column "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.ExportConfig{Format: types.FormatXLSX, Output: io.Discard, XLSX: tt.opts}
+			err := NewBuilder(config).Build(context.Background(), []string{"a"}, types.NewSliceSource(nil))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Build = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// buildSheets exports sheets as a workbook and returns its files
+func buildSheets(t *testing.T, opts types.XLSXOptions, sheets ...types.Sheet) (map[string]string, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	config := &types.ExportConfig{Mode: types.ModeParallel, Format: types.FormatXLSX, ChunkSize: 7, Output: &buf, XLSX: opts}
+	if err := NewBuilder(config).BuildSheets(context.Background(), types.NewSheetSource(sheets)); err != nil {
+		return nil, err
+	}
+	return readZip(t, buf.Bytes()), nil
+}
+
+func TestMultipleSheets(t *testing.T) {
+	files, err := buildSheets(t, types.XLSXOptions{SharedStrings: true},
+		types.Sheet{Name: "Orders", Headers: []string{"id"}, Source: types.NewSliceSource([]types.Row{{1}, {"x"}})},
+		types.Sheet{Headers: []string{"sku"}, Source: types.NewSliceSource([]types.Row{{"x"}})},
+	)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	containsAll(t, "workbook.xml", files["xl/workbook.xml"],
+		`<sheet name="Orders" sheetId="1" r:id="rId1"/>`,
+		`<sheet name="Sheet2" sheetId="2" r:id="rId2"/>`,
+	)
+	containsAll(t, "workbook.xml.rels", files["xl/_rels/workbook.xml.rels"],
+		`Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"`,
+		`Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"`,
+		`Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles"`,
+		`Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings"`,
+	)
+	containsAll(t, "[Content_Types].xml", files["[Content_Types].xml"],
+		`PartName="/xl/worksheets/sheet1.xml"`,
+		`PartName="/xl/worksheets/sheet2.xml"`,
+	)
+
+	// The sheets share one strings table
+	containsAll(t, "sharedStrings.xml", files["xl/sharedStrings.xml"],
+		`count="4" uniqueCount="3">
+  <si><t>id</t></si>
+  <si><t>x</t></si>
+  <si><t>sku</t></si>
+</sst>`)
+	got := cells(files["xl/worksheets/sheet2.xml"])
+	if got["A1"] != `<c r="A1" t="s"><v>2</v></c>` || got["A2"] != `<c r="A2" t="s"><v>1</v></c>` {
+		t.Fatalf("sheet2 cells = %v", got)
+	}
+}
+
+func TestWorkbookWithoutSheets(t *testing.T) {
+	files, err := buildSheets(t, types.XLSXOptions{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	containsAll(t, "workbook.xml", files["xl/workbook.xml"], `<sheet name="Sheet1" sheetId="1" r:id="rId1"/>`)
+	if strings.Contains(files["xl/worksheets/sheet1.xml"], "<row") {
+		t.Fatal("empty sheet has rows")
+	}
+}
+
+func TestSheetNameErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  string
+	}{
+		{"duplicate", []string{"Data", "data"}, `duplicate sheet name "data"`},
+		{"default name taken", []string{"Sheet2", ""}, `duplicate sheet name "Sheet2"`},
+		{"invalid character", []string{"a/b"}, `invalid sheet name "a/b"`},
+		{"too long", []string{strings.Repeat("x", 32)}, "longer than 31 characters"},
+		{"apostrophe", []string{"'quoted'"}, "must not start or end with an apostrophe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sheets []types.Sheet
+			for _, name := range tt.names {
+				sheets = append(sheets, types.Sheet{Name: name, Headers: []string{"a"}, Source: types.NewSliceSource(nil)})
+			}
+			_, err := buildSheets(t, types.XLSXOptions{}, sheets...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("build = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// numberedRows returns n rows holding their 1-based position
+func numberedRows(n int) []types.Row {
+	rows := make([]types.Row, n)
+	for i := range rows {
+		rows[i] = types.Row{i + 1}
+	}
+	return rows
+}
+
+var firstCellPattern = regexp.MustCompile(`<row r="(\d+)"><c r="A(\d+)"[^>]*>(?:<is><t>|<v>)([^<]*)<`)
+
+// columnA returns the value of the first cell of every row of a worksheet,
+// checking that rows are numbered from 1 without gaps
+func columnA(t *testing.T, sheet string) []string {
+	t.Helper()
+	var values []string
+	for i, m := range firstCellPattern.FindAllStringSubmatch(sheet, -1) {
+		if want := fmt.Sprint(i + 1); m[1] != want || m[2] != want {
+			t.Fatalf("row %d numbered %s with cell A%s", i+1, m[1], m[2])
+		}
+		values = append(values, m[3])
+	}
+	return values
+}
+
+func TestRollover(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   types.XLSXOptions
+		rows   int
+		sheets [][]string
+	}{
+		{
+			name:   "repeated headers",
+			opts:   types.XLSXOptions{MaxRowsPerSheet: 4, RepeatHeaders: true},
+			rows:   10,
+			sheets: [][]string{{"n", "1", "2", "3"}, {"n", "4", "5", "6"}, {"n", "7", "8", "9"}, {"n", "10"}},
+		},
+		{
+			name:   "header on the first sheet only",
+			opts:   types.XLSXOptions{MaxRowsPerSheet: 4},
+			rows:   10,
+			sheets: [][]string{{"n", "1", "2", "3"}, {"4", "5", "6", "7"}, {"8", "9", "10"}},
+		},
+		{
+			name:   "exact fit adds no empty sheet",
+			opts:   types.XLSXOptions{MaxRowsPerSheet: 4, RepeatHeaders: true},
+			rows:   6,
+			sheets: [][]string{{"n", "1", "2", "3"}, {"n", "4", "5", "6"}},
+		},
+		{
+			name:   "limit below the header keeps one row per sheet",
+			opts:   types.XLSXOptions{MaxRowsPerSheet: 1, RepeatHeaders: true},
+			rows:   2,
+			sheets: [][]string{{"n", "1"}, {"n", "2"}},
+		},
+		{
+			name:   "no limit",
+			opts:   types.XLSXOptions{},
+			rows:   10,
+			sheets: [][]string{{"n", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}},
+		},
+	}
+	for _, tt := range tests {
+		for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				files := build(t, mode, tt.opts, []string{"n"}, numberedRows(tt.rows))
+				for i, want := range tt.sheets {
+					name := fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)
+					if got := columnA(t, files[name]); !reflect.DeepEqual(got, want) {
+						t.Fatalf("%s holds %v, want %v", name, got, want)
+					}
+				}
+				if extra := fmt.Sprintf("xl/worksheets/sheet%d.xml", len(tt.sheets)+1); files[extra] != "" {
+					t.Fatalf("workbook has an extra sheet %s", extra)
+				}
+			})
+		}
+	}
+}
+
+func TestRolloverSheetNames(t *testing.T) {
+	var buf bytes.Buffer
+	config := &types.ExportConfig{Format: types.FormatXLSX, Output: &buf, XLSX: types.XLSXOptions{MaxRowsPerSheet: 2}}
+	builder := NewBuilder(config)
+	long := strings.Repeat("x", 31)
+	err := builder.BuildSheets(context.Background(), types.NewSheetSource([]types.Sheet{
+		{Name: "Orders", Headers: []string{"n"}, Source: types.NewSliceSource(numberedRows(3))},
+		{Name: long, Source: types.NewSliceSource(numberedRows(3))},
+		{Headers: []string{"n"}, Source: types.NewSliceSource(numberedRows(2))},
+	}))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	want := []types.SheetResult{
+		{Name: "Orders", Rows: 1},
+		{Name: "Orders (2)", Rows: 2},
+		{Name: long, Rows: 2},
+		{Name: strings.Repeat("x", 27) + " (2)", Rows: 1},
+		{Name: "Sheet5", Rows: 1},
+		{Name: "Sheet6", Rows: 1},
+	}
+	if got := builder.Sheets(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Sheets() = %+v, want %+v", got, want)
+	}
+	workbook := readZip(t, buf.Bytes())["xl/workbook.xml"]
+	for _, sheet := range want {
+		containsAll(t, "workbook.xml", workbook, `<sheet name="`+sheet.Name+`"`)
+	}
+}
+
+func TestWritePartRollover(t *testing.T) {
+	var buf bytes.Buffer
+	opts := types.XLSXOptions{MaxRowsPerSheet: 3, RepeatHeaders: true}
+	if err := WritePart(&buf, []string{"n"}, numberedRows(5), &types.SplitZipConfig{IncludeHeaders: true, XLSX: opts}); err != nil {
+		t.Fatalf("WritePart: %v", err)
+	}
+	files := readZip(t, buf.Bytes())
+	for i, want := range [][]string{{"n", "1", "2"}, {"n", "3", "4"}, {"n", "5"}} {
+		name := fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)
+		if got := columnA(t, files[name]); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s holds %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFreezeHeader(t *testing.T) {
+	opts := types.XLSXOptions{FreezeHeader: true, MaxRowsPerSheet: 3}
+	files := build(t, types.ModeSync, opts, []string{"n"}, numberedRows(4))
+
+	sheet1 := files["xl/worksheets/sheet1.xml"]
+	containsAll(t, "sheet1.xml", sheet1, `<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	if strings.Index(sheet1, "<sheetViews>") > strings.Index(sheet1, "<sheetData>") {
+		t.Fatal("<sheetViews> written after <sheetData>")
+	}
+	// The rows rolled over into sheet 2 have no header row to freeze
+	if strings.Contains(files["xl/worksheets/sheet2.xml"], "<sheetViews>") {
+		t.Fatal("sheet without a header row is frozen")
+	}
+}
+
+func TestAutoFilter(t *testing.T) {
+	files, err := buildSheets(t, types.XLSXOptions{AutoFilter: true},
+		types.Sheet{Name: "Data", Headers: []string{"a", "b"}, Source: types.NewSliceSource([]types.Row{{1, 2}, {3, 4}, {5, 6}})},
+		types.Sheet{Name: "It's", Headers: []string{"c"}, Source: types.NewSliceSource(nil)},
+		types.Sheet{Name: "NoHeader", Source: types.NewSliceSource([]types.Row{{1}})},
+	)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	sheet1 := files["xl/worksheets/sheet1.xml"]
+	containsAll(t, "sheet1.xml", sheet1, "</sheetData>\n  <autoFilter ref=\"A1:B4\"/>\n</worksheet>")
+	containsAll(t, "sheet2.xml", files["xl/worksheets/sheet2.xml"], `<autoFilter ref="A1:A1"/>`)
+	if strings.Contains(files["xl/worksheets/sheet3.xml"], "<autoFilter") {
+		t.Fatal("sheet without a header row has a filter")
+	}
+	containsAll(t, "workbook.xml", files["xl/workbook.xml"],
+		`<definedName name="_xlnm._FilterDatabase" localSheetId="0" hidden="1">&#39;Data&#39;!$A$1:$B$4</definedName>`,
+		`<definedName name="_xlnm._FilterDatabase" localSheetId="1" hidden="1">&#39;It&#39;&#39;s&#39;!$A$1:$A$1</definedName>`,
+	)
+}
+
+func TestTables(t *testing.T) {
+	opts := types.XLSXOptions{Table: true, TableName: "Sales", MaxRowsPerSheet: 3, RepeatHeaders: true}
+	files, err := buildSheets(t, opts,
+		types.Sheet{Headers: []string{"id", "a&b"}, Source: types.NewSliceSource([]types.Row{{1, 2}, {3, 4}, {5, 6}})},
+		types.Sheet{Headers: []string{"empty"}, Source: types.NewSliceSource(nil)},
+	)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	// Rows rolled over into sheet 2 get a table of their own
+	tables := []struct {
+		sheet, name, ref, columns string
+	}{
+		{"1", "Sales", "A1:B3", `<tableColumns count="2"><tableColumn id="1" name="id"/><tableColumn id="2" name="a&amp;b"/></tableColumns>`},
+		{"2", "Sales2", "A1:B2", `<tableColumn id="2" name="a&amp;b"/>`},
+		// A table spans at least one data row
+		{"3", "Sales3", "A1:A2", `<tableColumns count="1"><tableColumn id="1" name="empty"/></tableColumns>`},
+	}
+	for i, want := range tables {
+		id := fmt.Sprint(i + 1)
+		containsAll(t, "table"+id+".xml", files["xl/tables/table"+id+".xml"],
+			`id="`+id+`" name="`+want.name+`" displayName="`+want.name+`" ref="`+want.ref+`"`,
+			`<autoFilter ref="`+want.ref+`"/>`,
+			want.columns,
+			`<tableStyleInfo name="TableStyleMedium2"`,
+		)
+		containsAll(t, "sheet"+want.sheet+".xml.rels", files["xl/worksheets/_rels/sheet"+want.sheet+".xml.rels"],
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/table" Target="../tables/table`+id+`.xml"`)
+		sheet := files["xl/worksheets/sheet"+want.sheet+".xml"]
+		containsAll(t, "sheet"+want.sheet+".xml", sheet, `xmlns:r=`, `<tableParts count="1"><tablePart r:id="rId1"/></tableParts>`)
+		if strings.Contains(sheet, "<autoFilter") {
+			t.Fatalf("sheet %s has an autofilter besides its table", want.sheet)
+		}
+		containsAll(t, "[Content_Types].xml", files["[Content_Types].xml"],
+			`<Override PartName="/xl/tables/table`+id+`.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.table+xml"/>`)
+	}
+}
+
+func TestTableNames(t *testing.T) {
+	tests := []struct {
+		opts  types.XLSXOptions
+		names []string
+	}{
+		{types.XLSXOptions{Table: true}, []string{"Table1", "Table2"}},
+		{types.XLSXOptions{Table: true, TableName: "Orders", TableStyle: "TableStyleLight9"}, []string{"Orders", "Orders2"}},
+	}
+	for _, tt := range tests {
+		files, err := buildSheets(t, tt.opts,
+			types.Sheet{Headers: []string{"a"}, Source: types.NewSliceSource(nil)},
+			types.Sheet{Headers: []string{"a"}, Source: types.NewSliceSource(nil)},
+		)
+		if err != nil {
+			t.Fatalf("build: %v", err)
+		}
+		style := tt.opts.TableStyle
+		if style == "" {
+			style = DefaultTableStyle
+		}
+		for i, name := range tt.names {
+			id := fmt.Sprint(i + 1)
+			containsAll(t, "table"+id+".xml", files["xl/tables/table"+id+".xml"], ` name="`+name+`" `, `<tableStyleInfo name="`+style+`"`)
+		}
+	}
+}
+
+func TestTableErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    types.XLSXOptions
+		headers []string
+		want    string
+	}{
+		{"duplicate headers", types.XLSXOptions{Table: true}, []string{"Name", "name"}, `tables require unique, non-empty headers, got "name"`},
+		{"empty header", types.XLSXOptions{Table: true}, []string{"a", ""}, `tables require unique, non-empty headers, got ""`},
+		{"name with space", types.XLSXOptions{Table: true, TableName: "my table"}, []string{"a"}, `invalid table name "my table"`},
+		{"name like a cell", types.XLSXOptions{Table: true, TableName: "AB12"}, []string{"a"}, `invalid table name "AB12"`},
+		{"name starting with a digit", types.XLSXOptions{Table: true, TableName: "1st"}, []string{"a"}, `invalid table name "1st"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildSheets(t, tt.opts, types.Sheet{Headers: tt.headers, Source: types.NewSliceSource(nil)})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("build = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+var (
+	inlineTextPattern = regexp.MustCompile(`(?s)<is><t>(.*)</t></is>`)
+	sharedTextPattern = regexp.MustCompile(`(?s)<si><t>(.*?)</t></si>`)
+)
+
+// textOf returns the text of cell A2 of sheet 1 and, with shared strings,
+// checks that it comes from the strings table
+func textOf(t *testing.T, files map[string]string, shared bool) string {
+	t.Helper()
+	cell := cells(files["xl/worksheets/sheet1.xml"])["A2"]
+	if !shared {
+		m := inlineTextPattern.FindStringSubmatch(cell)
+		if m == nil {
+			t.Fatalf("cell A2 = %s, want an inline string", cell)
+		}
+		return m[1]
+	}
+	if !strings.Contains(cell, `t="s"><v>1</v>`) {
+		t.Fatalf("cell A2 = %s, want shared string 1", cell)
+	}
+	si := sharedTextPattern.FindAllStringSubmatch(files["xl/sharedStrings.xml"], -1)
+	return si[1][1]
+}
+
+func TestControlCharacters(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy types.ControlCharPolicy
+		value  string
+		want   string
+	}{
+		{"stripped by default", "", "a\x00b\x1Fc￾", "abc"},
+		{"strip", types.ControlCharsStrip, "a\x00b\x0Bc", "abc"},
+		{"replace", types.ControlCharsReplace, "a\x00b\x0Bc", "a�b�c"},
+		{"encode", types.ControlCharsEncode, "a\x00b\x0Bc￿", "a_x0000_b_x000B_c_xFFFF_"},
+		{"whitespace kept", types.ControlCharsStrip, "a\tb\nc\rd", "a\tb\nc\rd"},
+		{"invalid UTF-8 stripped", types.ControlCharsStrip, "a\xffb", "ab"},
+		{"invalid UTF-8 replaced", types.ControlCharsReplace, "a\xffb", "a�b"},
+		{"invalid UTF-8 cannot be encoded", types.ControlCharsEncode, "a\xffb", "a�b"},
+		{"literal escape protected", types.ControlCharsStrip, "_x0041_ and _x00e9_", "_x005F_x0041_ and _x005F_x00e9_"},
+		{"literal escape protected when encoding", types.ControlCharsEncode, "_x0041_\x01", "_x005F_x0041__x0001_"},
+		{"not an escape", types.ControlCharsStrip, "_x41_ _xZZZZ_", "_x41_ _xZZZZ_"},
+	}
+	for _, tt := range tests {
+		for _, shared := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/shared=%v", tt.name, shared), func(t *testing.T) {
+				opts := types.XLSXOptions{ControlChars: tt.policy, SharedStrings: shared}
+				files := build(t, types.ModeParallel, opts, []string{"h"}, []types.Row{{tt.value}})
+				if got := textOf(t, files, shared); got != tt.want {
+					t.Fatalf("text = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestControlCharactersInHeadersAndTables(t *testing.T) {
+	opts := types.XLSXOptions{ControlChars: types.ControlCharsEncode, Table: true}
+	files := build(t, types.ModeSync, opts, []string{"a\x01b"}, []types.Row{{1}})
+	if got := cells(files["xl/worksheets/sheet1.xml"])["A1"]; !strings.Contains(got, "<t>a_x0001_b</t>") {
+		t.Fatalf("header cell = %s, want the encoded character", got)
+	}
+	containsAll(t, "table1.xml", files["xl/tables/table1.xml"], `<tableColumn id="1" name="a_x0001_b"/>`)
+}
+
+func TestLongText(t *testing.T) {
+	emoji := "\U0001F600" // two UTF-16 code units
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"at the limit", strings.Repeat("x", MaxCellText), strings.Repeat("x", MaxCellText)},
+		{"over the limit", strings.Repeat("x", MaxCellText+10), strings.Repeat("x", MaxCellText)},
+		{"surrogate pair not split", strings.Repeat("x", MaxCellText-1) + emoji, strings.Repeat("x", MaxCellText-1)},
+		{"counted in UTF-16", strings.Repeat(emoji, MaxCellText/2+1), strings.Repeat(emoji, MaxCellText/2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := build(t, types.ModeSync, types.XLSXOptions{}, []string{"h"}, []types.Row{{tt.value}})
+			if got := textOf(t, files, false); got != tt.want {
+				t.Fatalf("text has %d bytes, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestTextPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts types.XLSXOptions
+		row  types.Row
+		want string
+	}{
+		{
+			name: "long text",
+			opts: types.XLSXOptions{LongText: types.LongTextError},
+			row:  types.Row{"ok", strings.Repeat("x", MaxCellText+1)},
+			want: "cell B2: text of 32768 characters exceeds Excel's cell limit of 32767",
+		},
+		{"control policy", types.XLSXOptions{ControlChars: "drop"}, types.Row{"ok"}, `invalid control character policy "drop"`},
+		{"long text policy", types.XLSXOptions{LongText: "wrap"}, types.Row{"ok"}, `invalid long text policy "wrap"`},
+	}
+	for _, tt := range tests {
+		for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				config := &types.ExportConfig{Mode: mode, Format: types.FormatXLSX, Output: io.Discard, XLSX: tt.opts}
+				err := NewBuilder(config).Build(context.Background(), []string{"a", "b"}, types.NewSliceSource([]types.Row{tt.row}))
+				if err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("Build = %v, want an error containing %q", err, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestDocumentProperties(t *testing.T) {
+	props := types.DocumentProperties{
+		Title:    "Q3 <Sales>",
+		Subject:  "Revenue",
+		Creator:  "Finance & Ops",
+		Company:  "Acme",
+		Keywords: "sales, q3",
+		Created:  time.Date(2024, 7, 1, 9, 30, 0, 0, time.FixedZone("CEST", 2*3600)),
+	}
+	files, err := buildSheets(t, types.XLSXOptions{Properties: props},
+		types.Sheet{Name: "Orders", Headers: []string{"a"}, Source: types.NewSliceSource(nil)},
+		types.Sheet{Name: "R&D", Headers: []string{"a"}, Source: types.NewSliceSource(nil)},
+	)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	containsAll(t, "core.xml", files["docProps/core.xml"],
+		"<dc:title>Q3 &lt;Sales&gt;</dc:title>",
+		"<dc:subject>Revenue</dc:subject>",
+		"<dc:creator>Finance &amp; Ops</dc:creator>",
+		"<cp:keywords>sales, q3</cp:keywords>",
+		// Timestamps are written in UTC
+		`<dcterms:created xsi:type="dcterms:W3CDTF">2024-07-01T07:30:00Z</dcterms:created>`,
+		`<dcterms:modified xsi:type="dcterms:W3CDTF">2024-07-01T07:30:00Z</dcterms:modified>`,
+	)
+	containsAll(t, "app.xml", files["docProps/app.xml"],
+		"<Application>turbo-export-engine</Application>",
+		"<vt:i4>2</vt:i4>",
+		`<vt:vector size="2" baseType="lpstr"><vt:lpstr>Orders</vt:lpstr><vt:lpstr>R&amp;D</vt:lpstr></vt:vector>`,
+		"<Company>Acme</Company>",
+	)
+	containsAll(t, ".rels", files["_rels/.rels"],
+		`Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"`,
+		`Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties" Target="docProps/app.xml"`,
+	)
+	containsAll(t, "[Content_Types].xml", files["[Content_Types].xml"],
+		`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>`,
+		`<Override PartName="/docProps/app.xml" ContentType="application/vnd.openxmlformats-officedocument.extended-properties+xml"/>`,
+	)
+}
+
+func TestEmptyDocumentPropertiesOmitted(t *testing.T) {
+	files := build(t, types.ModeSync, types.XLSXOptions{}, []string{"a"}, nil)
+	core := files["docProps/core.xml"]
+	for _, element := range []string{"<dc:title>", "<dc:creator>", "<cp:keywords>", "<dcterms:created"} {
+		if strings.Contains(core, element) {
+			t.Fatalf("core.xml has %s without the property set:\n%s", element, core)
+		}
+	}
+	if strings.Contains(files["docProps/app.xml"], "<Company>") {
+		t.Fatal("app.xml has a company without one set")
+	}
+}
+
+// streamed hides that a row source is held in memory
+type streamed struct {
+	types.RowSource
+}
+
+func TestSheetDimension(t *testing.T) {
+	rows := []types.Row{{1, 2}, {3, 4, 5}, {6}}
+	tests := []struct {
+		name    string
+		opts    types.XLSXOptions
+		headers []string
+		rows    []types.Row
+		want    []string // dimension of every sheet
+	}{
+		{"header and ragged rows", types.XLSXOptions{}, []string{"a", "b"}, rows, []string{"A1:C4"}},
+		{"rows without header", types.XLSXOptions{}, nil, rows, []string{"A1:C3"}},
+		{"header only", types.XLSXOptions{}, []string{"a", "b"}, nil, []string{"A1:B1"}},
+		{"empty sheet", types.XLSXOptions{}, nil, nil, []string{"A1"}},
+		{"single cell", types.XLSXOptions{}, []string{"a"}, nil, []string{"A1"}},
+		{
+			name:    "every rollover sheet",
+			opts:    types.XLSXOptions{MaxRowsPerSheet: 3, RepeatHeaders: true},
+			headers: []string{"a", "b"},
+			rows:    append(rows, types.Row{7, 8}, types.Row{9}),
+			want:    []string{"A1:C3", "A1:B3", "A1:B2"},
+		},
+	}
+	for _, tt := range tests {
+		for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				sources := map[string]types.RowSource{
+					"memory":   types.NewSliceSource(tt.rows),
+					"buffered": streamed{types.NewSliceSource(tt.rows)},
+				}
+				sheets := make(map[string]map[string]string)
+				for name, src := range sources {
+					opts := tt.opts
+					opts.BufferSheets = name == "buffered"
+
+					var buf bytes.Buffer
+					config := &types.ExportConfig{Mode: mode, Format: types.FormatXLSX, ChunkSize: 2, Output: &buf, XLSX: opts}
+					if err := NewBuilder(config).Build(context.Background(), tt.headers, src); err != nil {
+						t.Fatalf("%s build: %v", name, err)
+					}
+					sheets[name] = readZip(t, buf.Bytes())
+
+					for i, ref := range tt.want {
+						sheet := sheets[name][fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)]
+						containsAll(t, fmt.Sprintf("%s sheet%d.xml", name, i+1), sheet, `<dimension ref="`+ref+`"/>`)
+						if cols := strings.Index(sheet, "<cols>"); cols >= 0 && strings.Index(sheet, "<dimension") > cols {
+							t.Fatalf("%s sheet %d: <dimension> written after <cols>", name, i+1)
+						}
+					}
+				}
+				for i := range tt.want {
+					name := fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)
+					if sheets["memory"][name] != sheets["buffered"][name] {
+						t.Fatalf("buffered %s differs from the one over rows in memory", name)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestStreamedSheetWithoutBuffering(t *testing.T) {
+	for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+		var buf bytes.Buffer
+		config := &types.ExportConfig{Mode: mode, Format: types.FormatXLSX, ChunkSize: 2, Output: &buf}
+		src := streamed{types.NewSliceSource(numberedRows(5))}
+		if err := NewBuilder(config).Build(context.Background(), []string{"n"}, src); err != nil {
+			t.Fatalf("build: %v", err)
+		}
+		sheet := readZip(t, buf.Bytes())["xl/worksheets/sheet1.xml"]
+		if strings.Contains(sheet, "<dimension") {
+			t.Fatalf("%s: streamed sheet has a dimension without buffering", mode)
+		}
+		if got := columnA(t, sheet); len(got) != 6 {
+			t.Fatalf("%s: sheet has %d rows, want 6", mode, len(got))
+		}
+	}
+}
+
+func TestHyperlinks(t *testing.T) {
+	headers := []string{"site", "note"}
+	opts := types.XLSXOptions{ColumnTypes: map[string]types.CellType{"site": types.CellHyperlink}}
+	rows := []types.Row{
+		{"https://example.com/?a=1&b=2", "query"},
+		{map[string]interface{}{"url": "https://example.com/docs", "text": "Docs"}, "object"},
+		{"#'Sheet 2'!A1", "location"},
+		{"https://example.com/?a=1&b=2", "repeated"},
+		{"#", "no location"},
+		{map[string]interface{}{"text": "No URL"}, "no url"},
+		{"https://example.com/" + strings.Repeat("x", maxHyperlinkLength), "too long"},
+	}
+
+	var sheets []string
+	for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+		files := build(t, mode, opts, headers, rows)
+		sheet := files["xl/worksheets/sheet1.xml"]
+		containsAll(t, "sheet1.xml", sheet,
+			`<hyperlinks><hyperlink ref="A2" r:id="rId1"/><hyperlink ref="A3" r:id="rId2"/>`+
+				`<hyperlink ref="A4" location="&#39;Sheet 2&#39;!A1"/><hyperlink ref="A5" r:id="rId1"/></hyperlinks>`,
+			`No URL</t>`, `>Docs</t>`)
+		containsAll(t, "sheet1.xml.rels", files["xl/worksheets/_rels/sheet1.xml.rels"],
+			`Id="rId1" Type="`+relHyperlink+`" Target="https://example.com/?a=1&amp;b=2" TargetMode="External"/>`,
+			`Id="rId2" Type="`+relHyperlink+`" Target="https://example.com/docs" TargetMode="External"/>`)
+		containsAll(t, "styles.xml", files["xl/styles.xml"], `<font><u/><sz val="11"/><color rgb="FF0563C1"/>`)
+
+		// Links are styled, invalid ones written as plain text
+		got := cells(sheet)
+		for _, ref := range []string{"A2", "A3", "A4", "A5"} {
+			if !strings.Contains(got[ref], ` s="`) {
+				t.Errorf("%s: link %s is not styled: %s", mode, ref, got[ref])
+			}
+		}
+		for _, ref := range []string{"A6", "A7", "A8"} {
+			if strings.Contains(got[ref], ` s="`) {
+				t.Errorf("%s: invalid link %s is styled: %s", mode, ref, got[ref])
+			}
+		}
+		sheets = append(sheets, sheet)
+	}
+	if sheets[0] != sheets[1] {
+		t.Fatal("parallel sheet differs from the sync one")
+	}
+}
+
+func TestHyperlinksWithTable(t *testing.T) {
+	opts := types.XLSXOptions{
+		Table:       true,
+		ColumnTypes: map[string]types.CellType{"site": types.CellHyperlink},
+	}
+	files := build(t, types.ModeSync, opts, []string{"site"}, []types.Row{{"https://example.com"}})
+	containsAll(t, "sheet1.xml", files["xl/worksheets/sheet1.xml"],
+		`<hyperlinks><hyperlink ref="A2" r:id="rId2"/></hyperlinks>
+  <tableParts count="1"><tablePart r:id="rId1"/></tableParts>`)
+	containsAll(t, "sheet1.xml.rels", files["xl/worksheets/_rels/sheet1.xml.rels"],
+		`Id="rId1" Type="`+relTable+`" Target="../tables/table1.xml"/>`,
+		`Id="rId2" Type="`+relHyperlink+`" Target="https://example.com" TargetMode="External"/>`)
+}
+
+func TestHyperlinkLimit(t *testing.T) {
+	headers := []string{"site"}
+	rows := make([]types.Row, maxSheetHyperlinks+3)
+	for i := range rows {
+		rows[i] = types.Row{"https://example.com"}
+	}
+	last := maxSheetHyperlinks + 1 // row of the last link, after the header
+
+	var sheets []string
+	for _, shared := range []bool{false, true} {
+		opts := types.XLSXOptions{
+			SharedStrings: shared,
+			ColumnTypes:   map[string]types.CellType{"site": types.CellHyperlink},
+		}
+		for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+			name := fmt.Sprintf("%s/shared=%v", mode, shared)
+			sheet := build(t, mode, opts, headers, rows)["xl/worksheets/sheet1.xml"]
+			if n := strings.Count(sheet, "<hyperlink "); n != maxSheetHyperlinks {
+				t.Fatalf("%s: %d hyperlinks, want %d", name, n, maxSheetHyperlinks)
+			}
+			containsAll(t, name, sheet, fmt.Sprintf(`<hyperlink ref="A%d" r:id="rId1"/></hyperlinks>`, last))
+
+			got := cells(sheet[strings.Index(sheet, fmt.Sprintf(`<row r="%d">`, last)):])
+			if !strings.Contains(got[fmt.Sprintf("A%d", last)], ` s="`) {
+				t.Fatalf("%s: last link is not styled", name)
+			}
+			for row := last + 1; row <= len(rows)+1; row++ {
+				ref := fmt.Sprintf("A%d", row)
+				if cell := got[ref]; strings.Contains(cell, ` s="`) || !strings.Contains(cell, "<") {
+					t.Fatalf("%s: cell %s beyond the limit is not plain text: %q", name, ref, cell)
+				}
+			}
+			if !shared {
+				sheets = append(sheets, sheet)
+			}
+		}
+	}
+	if sheets[0] != sheets[1] {
+		t.Fatal("parallel sheet differs from the sync one")
+	}
+}
+
+func TestFormulas(t *testing.T) {
+	headers := []string{"a", "b", "total"}
+	opts := types.XLSXOptions{
+		ColumnTypes:   map[string]types.CellType{"total": types.CellFormula},
+		NumberFormats: map[string]string{"total": "0.00"},
+	}
+	rows := []types.Row{
+		{1, 2, "=SUM(A{row}:B{row})"},
+		{3, 4, `IF(A{row}>2,"big","small")`},
+		{5, 6, 11},
+		{7, 8, "="},
+	}
+	for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+		files := build(t, mode, opts, headers, rows)
+		got := cells(files["xl/worksheets/sheet1.xml"])
+		want := map[string]string{
+			"C2": `<c r="C2" s="3"><f>SUM(A2:B2)</f></c>`,
+			"C3": `<c r="C3" s="3"><f>IF(A3&gt;2,&#34;big&#34;,&#34;small&#34;)</f></c>`,
+			"C4": `<c r="C4" s="3"><v>11</v></c>`,
+			"C5": `<c r="C5" t="inlineStr"><is><t>=</t></is></c>`,
+		}
+		for ref, cell := range want {
+			if got[ref] != cell {
+				t.Errorf("%s: %s = %s, want %s", mode, ref, got[ref], cell)
+			}
+		}
+		containsAll(t, "workbook.xml", files["xl/workbook.xml"], `<calcPr fullCalcOnLoad="1"/>`)
+	}
+
+	files := build(t, types.ModeSync, types.XLSXOptions{}, headers, rows)
+	if strings.Contains(files["xl/workbook.xml"], "<calcPr") {
+		t.Fatal("workbook without formula columns is recalculated on load")
+	}
+}
+
+func TestHyperlinkMergeRestylesLinksOverLimit(t *testing.T) {
+	h := &hyperlinks{limit: 2, links: []hyperlink{{row: 2, target: "a"}}}
+	local := newChunkLinks()
+	for row := 3; row <= 5; row++ {
+		local.add(hyperlink{row: row, target: "b"})
+	}
+	xml := `<c r="A3" s="5" t="inlineStr"><is><t>x</t></is></c>` +
+		`<c r="A4" s="6" t="s"><v>0</v></c>` +
+		`<c r="A5" s="5" t="inlineStr"><is><t>y</t></is></c>` +
+		`<c r="A50" s="5" t="inlineStr"><is><t>z</t></is></c>`
+
+	got := h.merge(local, xml, map[int]int{5: 0, 6: 7})
+	want := `<c r="A3" s="5" t="inlineStr"><is><t>x</t></is></c>` +
+		`<c r="A4" s="7" t="s"><v>0</v></c>` +
+		`<c r="A5" t="inlineStr"><is><t>y</t></is></c>` +
+		`<c r="A50" s="5" t="inlineStr"><is><t>z</t></is></c>`
+	if got != want {
+		t.Fatalf("merged XML:\n%s\nwant:\n%s", got, want)
+	}
+	if len(h.links) != 2 || h.links[1].row != 3 {
+		t.Fatalf("links = %+v, want rows 2 and 3", h.links)
+	}
+	if h.add(hyperlink{row: 6}) {
+		t.Fatal("link added beyond the limit")
+	}
+}
+
+// cellXfs returns the cell formats of styles.xml
+func cellXfs(t *testing.T, styles string) []string {
+	t.Helper()
+	start := strings.Index(styles, "<cellXfs")
+	end := strings.Index(styles, "</cellXfs>")
+	if start < 0 || end < 0 {
+		t.Fatalf("styles.xml has no cellXfs:\n%s", styles)
+	}
+	return regexp.MustCompile(`<xf [^>]*/>`).FindAllString(styles[start:end], -1)
+}
+
+// styleOf returns the cell format of a cell
+func styleOf(t *testing.T, xfs []string, cell string) string {
+	t.Helper()
+	idx := 0
+	if m := regexp.MustCompile(` s="([0-9]+)"`).FindStringSubmatch(cell); m != nil {
+		fmt.Sscan(m[1], &idx)
+	}
+	if idx >= len(xfs) {
+		t.Fatalf("cell %s has style %d of %d", cell, idx, len(xfs))
+	}
+	return xfs[idx]
+}
+
+func TestInjectionQuotePrefix(t *testing.T) {
+	headers := []string{"=name", "site", "comment"}
+	opts := types.XLSXOptions{
+		BoldHeaders: true,
+		ColumnTypes: map[string]types.CellType{"site": types.CellHyperlink},
+	}
+	protect := types.InjectionOptions{
+		Policy:  types.InjectionPrefix,
+		Columns: map[string]types.InjectionPolicy{"comment": types.InjectionOff},
+	}
+	rows := []types.Row{
+		{"=SUM(A1)", map[string]interface{}{"url": "https://example.com", "text": "@home"}, "=1+1"},
+		{"-12.5", "https://example.com", "ok"},
+		{-3, "+site", "@x"},
+	}
+	tests := map[string]struct {
+		text   string
+		quoted bool
+	}{
+		"A1": {"=name", true},
+		"B1": {"site", false},
+		"A2": {"=SUM(A1)", true},
+		"B2": {"@home", true},
+		"C2": {"=1+1", false},
+		"A3": {"-12.5", false},
+		"B3": {"https://example.com", false},
+		"B4": {"+site", true},
+		"C4": {"@x", false},
+	}
+
+	var sheets []string
+	for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+		files, err := buildProtected(t, mode, opts, protect, headers, rows)
+		if err != nil {
+			t.Fatalf("%s: build: %v", mode, err)
+		}
+		sheet := files["xl/worksheets/sheet1.xml"]
+		xfs := cellXfs(t, files["xl/styles.xml"])
+		got := cells(sheet)
+		for ref, want := range tests {
+			cell := got[ref]
+			if !strings.Contains(cell, "<t>"+xmlText(want.text)+"</t>") {
+				t.Errorf("%s: cell %s = %s, want the text %q unchanged", mode, ref, cell, want.text)
+			}
+			xf := styleOf(t, xfs, cell)
+			if quoted := strings.Contains(xf, `quotePrefix="1"`); quoted != want.quoted {
+				t.Errorf("%s: cell %s has format %s, want quotePrefix %v", mode, ref, xf, want.quoted)
+			}
+		}
+		// Quoted cells keep the rest of their style
+		for _, ref := range []string{"A1", "B2"} {
+			if xf := styleOf(t, xfs, got[ref]); !strings.Contains(xf, `applyFont="1"`) {
+				t.Errorf("%s: cell %s lost its font: %s", mode, ref, xf)
+			}
+		}
+		if got["A4"] != `<c r="A4"><v>-3</v></c>` {
+			t.Errorf("%s: number cell = %s", mode, got["A4"])
+		}
+		// Protected links stay links
+		containsAll(t, "sheet1.xml", sheet, `<hyperlink ref="B2" r:id="rId1"/>`, `<hyperlink ref="B3" r:id="rId1"/>`)
+		sheets = append(sheets, sheet)
+	}
+	if sheets[0] != sheets[1] {
+		t.Fatal("parallel sheet differs from the sync one")
+	}
+}
+
+func TestInjectionStripAndReject(t *testing.T) {
+	headers := []string{"name", "note"}
+	rows := []types.Row{{"=cmd", "+1"}, {"@@x", "-2"}}
+
+	files, err := buildProtected(t, types.ModeParallel, types.XLSXOptions{}, types.InjectionOptions{Policy: types.InjectionStrip}, headers, rows)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	got := cells(files["xl/worksheets/sheet1.xml"])
+	for ref, text := range map[string]string{"A2": "cmd", "B2": "+1", "A3": "x", "B3": "-2"} {
+		if !strings.Contains(got[ref], "<t>"+text+"</t>") {
+			t.Errorf("cell %s = %s, want %q", ref, got[ref], text)
+		}
+	}
+	if strings.Contains(files["xl/styles.xml"], "quotePrefix") {
+		t.Error("strip registered a quote-prefix style")
+	}
+
+	for _, mode := range []types.ExportMode{types.ModeSync, types.ModeParallel} {
+		protect := types.InjectionOptions{Columns: map[string]types.InjectionPolicy{"name": types.InjectionReject}}
+		_, err := buildProtected(t, mode, types.XLSXOptions{}, protect, headers, rows)
+		if want := `cell A2: column "name": text starting with '='`; err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: build = %v, want an error containing %q", mode, err, want)
+		}
+	}
+}
+
+func TestInjectionTableHeaders(t *testing.T) {
+	rows := []types.Row{{1, 2}}
+	tests := []struct {
+		name    string
+		headers []string
+		policy  types.InjectionPolicy
+		columns string // tableColumns of the table part
+		header  string // text of cell A1
+		err     string
+	}{
+		{
+			name:    "prefix keeps the header text",
+			headers: []string{"=total", "count"},
+			policy:  types.InjectionPrefix,
+			columns: `<tableColumn id="1" name="=total"/><tableColumn id="2" name="count"/>`,
+			header:  "=total",
+		},
+		{
+			name:    "strip renames the column like the header",
+			headers: []string{"=total", "count"},
+			policy:  types.InjectionStrip,
+			columns: `<tableColumn id="1" name="total"/><tableColumn id="2" name="count"/>`,
+			header:  "total",
+		},
+		{
+			name:    "stripped headers must stay unique",
+			headers: []string{"=total", "total"},
+			policy:  types.InjectionStrip,
+			err:     `tables require unique, non-empty headers, got "total"`,
+		},
+		{
+			name:    "stripped headers must not be empty",
+			headers: []string{"=", "count"},
+			policy:  types.InjectionStrip,
+			err:     `tables require unique, non-empty headers, got ""`,
+		},
+		{
+			name:    "reject",
+			headers: []string{"@total", "count"},
+			policy:  types.InjectionReject,
+			err:     `cell A1: column "@total": text starting with '@'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protect := types.InjectionOptions{Policy: tt.policy}
+			files, err := buildProtected(t, types.ModeSync, types.XLSXOptions{Table: true}, protect, tt.headers, rows)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("build = %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			containsAll(t, "table1.xml", files["xl/tables/table1.xml"], tt.columns)
+			if got := cells(files["xl/worksheets/sheet1.xml"])["A1"]; !strings.Contains(got, "<t>"+tt.header+"</t>") {
+				t.Fatalf("header cell = %s, want %q", got, tt.header)
+			}
+		})
+	}
+}
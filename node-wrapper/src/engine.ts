@@ -45,11 +45,10 @@ export class ExportEngine {
     rows: Row[],
     options: ExportOptions = {}
   ): Promise<Buffer> {
-    const tmpOutput = path.join(os.tmpdir(), `export-${Date.now()}.csv`);
-    await this.exportCSV(headers, rows, tmpOutput, options);
-    const buffer = await fs.readFile(tmpOutput);
-    await fs.unlink(tmpOutput);
-    return buffer;
+    return this.executeBinaryPiped(
+      ['csv', ...this.exportArgs(options)],
+      { headers, rows }
+    );
   }
 
   async exportXLSXBuffer(
@@ -57,11 +56,10 @@ export class ExportEngine {
     rows: Row[],
     options: ExportOptions = {}
   ): Promise<Buffer> {
-    const tmpOutput = path.join(os.tmpdir(), `export-${Date.now()}.xlsx`);
-    await this.exportXLSX(headers, rows, tmpOutput, options);
-    const buffer = await fs.readFile(tmpOutput);
-    await fs.unlink(tmpOutput);
-    return buffer;
+    return this.executeBinaryPiped(
+      ['xlsx', ...this.exportArgs(options)],
+      { headers, rows }
+    );
   }
 
   async splitZip(
@@ -85,7 +83,7 @@ export class ExportEngine {
         '--workers', String(options.workers || 4),
         '--chunk-size', String(options.chunkSize || 10000),
         '--format', options.format || 'csv',
-        '--include-headers', String(options.includeHeaders !== false),
+        `--include-headers=${options.includeHeaders !== false}`,
         '--input', tmpInput,
         '--output', outputPath,
       ];
@@ -125,11 +123,23 @@ export class ExportEngine {
     rows: Row[],
     options: SplitZipOptions = {}
   ): Promise<Buffer> {
-    const tmpOutput = path.join(os.tmpdir(), `split-export-${Date.now()}.zip`);
-    await this.splitZip(headers, rows, tmpOutput, options);
-    const buffer = await fs.readFile(tmpOutput);
-    await fs.unlink(tmpOutput);
-    return buffer;
+    return this.executeBinaryPiped(
+      [
+        'split-zip',
+        ...this.exportArgs(options),
+        '--format', options.format || 'csv',
+        `--include-headers=${options.includeHeaders !== false}`,
+      ],
+      { headers, rows }
+    );
+  }
+
+  private exportArgs(options: ExportOptions): string[] {
+    return [
+      '--mode', options.mode || 'parallel',
+      '--workers', String(options.workers || 4),
+      '--chunk-size', String(options.chunkSize || 10000),
+    ];
   }
 
   private async export(
@@ -173,6 +183,50 @@ export class ExportEngine {
     }
   }
 
+  // Streams the input document over stdin and collects the exported bytes
+  // from stdout, avoiding temporary files on both sides
+  private executeBinaryPiped(args: string[], data: ExportData): Promise<Buffer> {
+    return new Promise((resolve, reject) => {
+      const child = spawn(
+        this.binaryPath,
+        [...args, '--input', '-', '--output', '-'],
+        { stdio: ['pipe', 'pipe', 'pipe'] }
+      );
+
+      const chunks: Buffer[] = [];
+      let stderr = '';
+
+      child.stdout?.on('data', (chunk: Buffer) => {
+        chunks.push(chunk);
+      });
+
+      child.stderr?.on('data', (data) => {
+        stderr += data.toString();
+      });
+
+      child.on('error', (error) => {
+        reject(new Error(`Failed to spawn binary: ${error.message}`));
+      });
+
+      // The process may exit early on invalid input; its exit code reports it
+      child.stdin?.on('error', () => {});
+
+      child.on('close', (code) => {
+        if (code !== 0) {
+          reject(
+            new Error(
+              `Export process exited with code ${code}\nStderr: ${stderr}`
+            )
+          );
+        } else {
+          resolve(Buffer.concat(chunks));
+        }
+      });
+
+      child.stdin?.end(JSON.stringify(data));
+    });
+  }
+
   private executeBinary(args: string[]): Promise<void> {
     return new Promise((resolve, reject) => {
       const child = spawn(this.binaryPath, args, {
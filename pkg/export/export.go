@@ -0,0 +1,243 @@
+// Package export is the public Go API of the export engine. It writes CSV,
+// XLSX or split ZIP archives to any io.Writer using the same writers as the
+// export-engine binary.
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/turbo-export-engine/internal/csv"
+	"github.com/turbo-export-engine/internal/injection"
+	"github.com/turbo-export-engine/internal/job"
+	"github.com/turbo-export-engine/internal/missing"
+	"github.com/turbo-export-engine/internal/splitzip"
+	"github.com/turbo-export-engine/internal/values"
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// Exporter runs exports with a fixed set of options. It is safe for
+// concurrent use.
+type Exporter struct {
+	opts options
+}
+
+// Result describes a completed export
+type Result struct {
+	Format    types.ExportFormat
+	Mode      types.ExportMode
+	Rows      int
+	Parts     int                 // number of part files, only set for split ZIP exports
+	PartFiles []string            // names of the part files inside the ZIP archive
+	Sheets    []types.SheetResult // worksheets of XLSX exports, including rollover sheets
+	// LossyCells counts the CSV cells replaced or transliterated to fit
+	// the output charset
+	LossyCells int
+	Duration   time.Duration
+}
+
+// New creates an Exporter with the given options
+func New(opts ...Option) *Exporter {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Exporter{opts: o}
+}
+
+// Export pulls rows from src and writes the export to w. The source is not
+// closed.
+func (e *Exporter) Export(w io.Writer, headers []string, src types.RowSource) (*Result, error) {
+	return e.ExportContext(context.Background(), w, headers, src)
+}
+
+// ExportContext is like Export but stops once ctx is done, returning
+// ctx.Err(). Bytes already written to w are not taken back.
+func (e *Exporter) ExportContext(ctx context.Context, w io.Writer, headers []string, src types.RowSource) (*Result, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	counted := &countingSource{RowSource: src}
+
+	result := &Result{
+		Format: e.opts.format,
+		Mode:   e.opts.mode,
+	}
+
+	if e.opts.splitZip {
+		splitResult, err := e.splitZip(ctx, w, headers, counted)
+		if err != nil {
+			return nil, err
+		}
+		result.Parts = splitResult.TotalParts
+		result.PartFiles = splitResult.PartFiles
+		result.LossyCells = splitResult.LossyCells
+	} else {
+		exportJob := &types.ExportJob{Headers: headers, Source: counted.source()}
+		if err := e.export(ctx, w, exportJob); err != nil {
+			return nil, err
+		}
+		result.Sheets = exportJob.SheetResults
+		result.LossyCells = exportJob.LossyCells
+	}
+
+	result.Rows = counted.count
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// ExportRows writes materialized rows to w
+func (e *Exporter) ExportRows(w io.Writer, headers []string, rows []types.Row) (*Result, error) {
+	return e.Export(w, headers, types.NewSliceSource(rows))
+}
+
+// ExportSheets writes an XLSX workbook with one worksheet per sheet, in
+// order. It requires the xlsx format without split ZIP. The sheet sources
+// are not closed.
+func (e *Exporter) ExportSheets(ctx context.Context, w io.Writer, sheets []types.Sheet) (*Result, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	if e.opts.format != types.FormatXLSX || e.opts.splitZip {
+		return nil, fmt.Errorf("multi-sheet exports require the xlsx format without split ZIP")
+	}
+
+	start := time.Now()
+	counted := make([]*countingSource, len(sheets))
+	countedSheets := make([]types.Sheet, len(sheets))
+	for i, sheet := range sheets {
+		counted[i] = &countingSource{RowSource: sheet.Source}
+		sheet.Source = counted[i].source()
+		countedSheets[i] = sheet
+	}
+
+	exportJob := &types.ExportJob{Sheets: types.NewSheetSource(countedSheets)}
+	if err := e.export(ctx, w, exportJob); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Format:   e.opts.format,
+		Mode:     e.opts.mode,
+		Sheets:   exportJob.SheetResults,
+		Duration: time.Since(start),
+	}
+	for _, c := range counted {
+		result.Rows += c.count
+	}
+	return result, nil
+}
+
+// export fills in the job's ID and configuration and runs it
+func (e *Exporter) export(ctx context.Context, w io.Writer, exportJob *types.ExportJob) error {
+	exportJob.ID = fmt.Sprintf("export-%d", time.Now().UnixNano())
+	exportJob.Config = &types.ExportConfig{
+		Mode:      e.opts.mode,
+		Format:    e.opts.format,
+		Workers:   e.opts.workers,
+		ChunkSize: e.opts.chunkSize,
+		Output:    w,
+		XLSX:      e.opts.xlsx,
+		CSV:       e.opts.csv,
+		Injection: e.opts.injection,
+		Missing:   e.opts.missing,
+		Numbers:   e.opts.numbers,
+	}
+
+	switch e.opts.mode {
+	case types.ModeParallel:
+		return job.NewParallelExecutor().ExecuteContext(ctx, exportJob)
+	case types.ModeGlobalPool:
+		return job.NewPoolExecutor(e.opts.workers).ExecuteContext(ctx, exportJob)
+	default:
+		return job.NewSyncExecutor().ExecuteContext(ctx, exportJob)
+	}
+}
+
+func (e *Exporter) splitZip(ctx context.Context, w io.Writer, headers []string, src types.RowSource) (*types.SplitZipResult, error) {
+	splitter := splitzip.NewSplitter(&types.SplitZipConfig{
+		Split:          true,
+		Zip:            true,
+		ChunkSize:      e.opts.chunkSize,
+		Format:         e.opts.format,
+		Mode:           e.opts.mode,
+		Workers:        e.opts.workers,
+		IncludeHeaders: e.opts.includeHeaders,
+		Output:         w,
+		XLSX:           e.opts.xlsx,
+		CSV:            e.opts.csv,
+		Injection:      e.opts.injection,
+		Missing:        e.opts.missing,
+		Numbers:        e.opts.numbers,
+	})
+	return splitter.ExecuteContext(ctx, headers, src)
+}
+
+func (e *Exporter) validate() error {
+	switch e.opts.mode {
+	case types.ModeSync, types.ModeParallel, types.ModeGlobalPool:
+	default:
+		return fmt.Errorf("unsupported mode: %s", e.opts.mode)
+	}
+	switch e.opts.format {
+	case types.FormatCSV, types.FormatXLSX:
+	default:
+		return fmt.Errorf("unsupported format: %s", e.opts.format)
+	}
+	if e.opts.workers <= 0 {
+		return fmt.Errorf("workers must be positive, got %d", e.opts.workers)
+	}
+	if e.opts.chunkSize <= 0 {
+		return fmt.Errorf("chunk size must be positive, got %d", e.opts.chunkSize)
+	}
+	if err := csv.CheckOptions(e.opts.csv); err != nil {
+		return err
+	}
+	if err := injection.CheckOptions(e.opts.injection); err != nil {
+		return err
+	}
+	if err := missing.CheckOptions(e.opts.missing); err != nil {
+		return err
+	}
+	if err := values.CheckOptions(e.opts.numbers); err != nil {
+		return err
+	}
+	return nil
+}
+
+// countingSource counts the rows pulled through it
+type countingSource struct {
+	types.RowSource
+	count int
+}
+
+func (s *countingSource) Next() bool {
+	if !s.RowSource.Next() {
+		return false
+	}
+	s.count++
+	return true
+}
+
+// source returns s as a RowSource, keeping the pending rows of in-memory
+// sources visible so writers can size their output
+func (s *countingSource) source() types.RowSource {
+	if memory, ok := s.RowSource.(types.MemorySource); ok {
+		return countingMemorySource{countingSource: s, memory: memory}
+	}
+	return s
+}
+
+// countingMemorySource is a countingSource over rows held in memory
+type countingMemorySource struct {
+	*countingSource
+	memory types.MemorySource
+}
+
+func (s countingMemorySource) Pending() []types.Row {
+	return s.memory.Pending()
+}
@@ -0,0 +1,408 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/turbo-export-engine/pkg/export"
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+var testHeaders = []string{"ID", "Name", "Score"}
+
+// testRows returns n rows of distinct values
+func testRows(n int) []types.Row {
+	rows := make([]types.Row, n)
+	for i := range rows {
+		rows[i] = types.Row{i + 1, fmt.Sprintf("name %d", i+1), float64(i) + 0.5}
+	}
+	return rows
+}
+
+// expectedCSV is the CSV of testHeaders and testRows(n)
+func expectedCSV(n int) string {
+	var sb strings.Builder
+	sb.WriteString("ID,Name,Score\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "%d,name %d,%v\n", i+1, i+1, float64(i)+0.5)
+	}
+	return sb.String()
+}
+
+// readZip returns the contents of every file in a ZIP archive by name
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+// checkSheet checks that a worksheet holds the header row and n data rows
+func checkSheet(t *testing.T, sheet string, n int) {
+	t.Helper()
+	if got := strings.Count(sheet, "<row "); got != n+1 {
+		t.Fatalf("sheet has %d rows, want %d", got, n+1)
+	}
+	for _, want := range []string{">Name<", fmt.Sprintf(">name %d<", n), fmt.Sprintf(`<row r="%d"`, n+1)} {
+		if !strings.Contains(sheet, want) {
+			t.Fatalf("sheet does not contain %q", want)
+		}
+	}
+}
+
+func TestExportModesAndFormats(t *testing.T) {
+	const n = 2500
+	modes := []types.ExportMode{types.ModeSync, types.ModeParallel, types.ModeGlobalPool}
+	formats := []types.ExportFormat{types.FormatCSV, types.FormatXLSX}
+
+	for _, mode := range modes {
+		for _, format := range formats {
+			t.Run(fmt.Sprintf("%s/%s", mode, format), func(t *testing.T) {
+				exporter := export.New(
+					export.WithMode(mode),
+					export.WithFormat(format),
+					export.WithWorkers(3),
+					export.WithChunkSize(1000),
+				)
+
+				var buf bytes.Buffer
+				result, err := exporter.ExportRows(&buf, testHeaders, testRows(n))
+				if err != nil {
+					t.Fatalf("export: %v", err)
+				}
+				if result.Rows != n || result.Mode != mode || result.Format != format {
+					t.Fatalf("result = %+v, want %d rows in %s/%s", result, n, mode, format)
+				}
+
+				switch format {
+				case types.FormatCSV:
+					if got := buf.String(); got != expectedCSV(n) {
+						t.Fatalf("CSV differs from the expected output:\n%.300s", got)
+					}
+				case types.FormatXLSX:
+					checkSheet(t, readZip(t, buf.Bytes())["xl/worksheets/sheet1.xml"], n)
+				}
+			})
+		}
+	}
+}
+
+// cancellingSource counts the rows read and calls cancel at row cancelAt
+type cancellingSource struct {
+	types.RowSource
+	read     atomic.Int64
+	cancelAt int64
+	cancel   context.CancelFunc
+}
+
+func (s *cancellingSource) Next() bool {
+	if s.read.Add(1) == s.cancelAt {
+		s.cancel()
+	}
+	return s.RowSource.Next()
+}
+
+func TestExportContextStopsWhenCancelled(t *testing.T) {
+	modes := []types.ExportMode{types.ModeSync, types.ModeParallel, types.ModeGlobalPool}
+	formats := []types.ExportFormat{types.FormatCSV, types.FormatXLSX}
+
+	for _, mode := range modes {
+		for _, format := range formats {
+			t.Run(fmt.Sprintf("%s/%s", mode, format), func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				src := &cancellingSource{RowSource: types.NewSliceSource(testRows(20000)), cancelAt: 1500, cancel: cancel}
+
+				exporter := export.New(export.WithMode(mode), export.WithFormat(format), export.WithChunkSize(100))
+				_, err := exporter.ExportContext(ctx, io.Discard, testHeaders, src)
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("ExportContext = %v, want context.Canceled", err)
+				}
+
+				read := src.read.Load()
+				if read >= 20000 {
+					t.Fatal("the whole source was read after cancellation")
+				}
+				time.Sleep(10 * time.Millisecond)
+				if src.read.Load() != read {
+					t.Fatal("source read after ExportContext returned")
+				}
+			})
+		}
+	}
+}
+
+func TestExportStreamsSource(t *testing.T) {
+	const n = 1234
+	var buf bytes.Buffer
+	result, err := export.New(export.WithMode(types.ModeParallel), export.WithChunkSize(100)).
+		Export(&buf, testHeaders, types.NewSliceSource(testRows(n)))
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if result.Rows != n || buf.String() != expectedCSV(n) {
+		t.Fatalf("exported %d rows, want %d with the expected CSV", result.Rows, n)
+	}
+}
+
+func TestExportSplitZip(t *testing.T) {
+	for _, format := range []types.ExportFormat{types.FormatCSV, types.FormatXLSX} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			result, err := export.New(
+				export.WithFormat(format),
+				export.WithChunkSize(40),
+				export.WithSplitZip(true),
+			).ExportRows(&buf, testHeaders, testRows(100))
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			if result.Parts != 3 || len(result.PartFiles) != 3 || result.Rows != 100 {
+				t.Fatalf("result = %+v, want 3 parts of 100 rows", result)
+			}
+
+			files := readZip(t, buf.Bytes())
+			for _, name := range result.PartFiles {
+				if _, ok := files[name]; !ok {
+					t.Fatalf("archive lacks part %s; has %d files", name, len(files))
+				}
+			}
+		})
+	}
+}
+
+func TestExportSplitZipDialect(t *testing.T) {
+	var buf bytes.Buffer
+	result, err := export.New(
+		export.WithChunkSize(2),
+		export.WithSplitZip(true),
+		export.WithCSVOptions(types.CSVOptions{Delimiter: '\t', CRLF: true}),
+	).ExportRows(&buf, testHeaders, testRows(3))
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	// Tab-delimited parts keep the .csv name clients look for
+	want := map[string]string{
+		"part_1.csv": "ID\tName\tScore\r\n1\tname 1\t0.5\r\n2\tname 2\t1.5\r\n",
+		"part_2.csv": "ID\tName\tScore\r\n3\tname 3\t2.5\r\n",
+	}
+	files := readZip(t, buf.Bytes())
+	if len(result.PartFiles) != len(want) || len(files) != len(want) {
+		t.Fatalf("parts = %v, want %d", result.PartFiles, len(want))
+	}
+	for _, name := range result.PartFiles {
+		if files[name] != want[name] {
+			t.Fatalf("%s = %q, want %q", name, files[name], want[name])
+		}
+	}
+}
+
+func TestExportLossyCells(t *testing.T) {
+	rows := []types.Row{{1, "Łódź", 0.5}, {2, "plain", 1.5}, {3, "Gdańsk", "Ł"}, {4, "€", 3.5}, {5, "Kraków", 4.5}}
+	csvOpts := export.WithCSVOptions(types.CSVOptions{Charset: "windows-1252", Unencodable: types.UnencodableReplace})
+	tests := []struct {
+		name string
+		opts []export.Option
+	}{
+		{"sync", []export.Option{export.WithMode(types.ModeSync)}},
+		{"parallel", []export.Option{export.WithMode(types.ModeParallel), export.WithChunkSize(2)}},
+		{"global pool", []export.Option{export.WithMode(types.ModeGlobalPool)}},
+		{"split zip", []export.Option{export.WithMode(types.ModeSync), export.WithChunkSize(2), export.WithSplitZip(true)}},
+		{"parallel split zip", []export.Option{export.WithMode(types.ModeParallel), export.WithChunkSize(2), export.WithSplitZip(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			result, err := export.New(append(tt.opts, csvOpts)...).ExportRows(&buf, testHeaders, rows)
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			if result.LossyCells != 3 {
+				t.Fatalf("LossyCells = %d, want 3", result.LossyCells)
+			}
+		})
+	}
+}
+
+func TestExportInjection(t *testing.T) {
+	rows := []types.Row{{1, "=cmd", -1.5}, {2, "-3", "@x"}}
+	protect := export.WithInjection(types.InjectionOptions{
+		Policy:  types.InjectionPrefix,
+		Columns: map[string]types.InjectionPolicy{"Score": types.InjectionOff},
+	})
+
+	var csvOut bytes.Buffer
+	if _, err := export.New(protect).ExportRows(&csvOut, testHeaders, rows); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if want := "ID,Name,Score\n1,'=cmd,-1.5\n2,-3,@x\n"; csvOut.String() != want {
+		t.Fatalf("csv = %q, want %q", csvOut.String(), want)
+	}
+
+	for _, format := range []types.ExportFormat{types.FormatCSV, types.FormatXLSX} {
+		var buf bytes.Buffer
+		_, err := export.New(protect, export.WithFormat(format), export.WithSplitZip(true)).ExportRows(&buf, testHeaders, rows)
+		if err != nil {
+			t.Fatalf("%s split zip: %v", format, err)
+		}
+		files := readZip(t, buf.Bytes())
+		if format == types.FormatCSV {
+			if got := files["part_1.csv"]; got != csvOut.String() {
+				t.Fatalf("csv part = %q, want %q", got, csvOut.String())
+			}
+			continue
+		}
+
+		part := readZip(t, []byte(files["part_1.xlsx"]))
+		if !strings.Contains(part["xl/styles.xml"], `quotePrefix="1"`) || strings.Contains(part["xl/worksheets/sheet1.xml"], "'=cmd") {
+			t.Fatal("xlsx part cell is not marked with a quote-prefix style")
+		}
+	}
+
+	_, err := export.New(export.WithInjection(types.InjectionOptions{Policy: types.InjectionReject})).
+		ExportRows(io.Discard, testHeaders, rows)
+	if err == nil || !strings.Contains(err.Error(), `row 1: column "Name"`) {
+		t.Fatalf("reject = %v, want an error naming row 1 and column Name", err)
+	}
+}
+
+func TestExportMissing(t *testing.T) {
+	rows := []types.Row{{1, nil, 0.5}, {2}, {3, "c", 2.5, "extra"}}
+	missing := export.WithMissing(types.MissingOptions{Null: `\N`, Ragged: types.RaggedTruncate})
+	want := "ID,Name,Score\n1,\\N,0.5\n2,\\N,\\N\n3,c,2.5\n"
+	tests := []struct {
+		name string
+		opts []export.Option
+	}{
+		{"sync", []export.Option{export.WithMode(types.ModeSync)}},
+		{"parallel", []export.Option{export.WithMode(types.ModeParallel), export.WithChunkSize(1)}},
+		{"global pool", []export.Option{export.WithMode(types.ModeGlobalPool), export.WithChunkSize(1)}},
+		{"split zip", []export.Option{export.WithChunkSize(10), export.WithSplitZip(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := export.New(append(tt.opts, missing)...).ExportRows(&buf, testHeaders, rows); err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			got := buf.String()
+			if tt.name == "split zip" {
+				got = readZip(t, buf.Bytes())["part_1.csv"]
+			}
+			if got != want {
+				t.Fatalf("csv = %q, want %q", got, want)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	_, err := export.New(export.WithFormat(types.FormatXLSX), missing).ExportRows(&buf, testHeaders, rows)
+	if err != nil {
+		t.Fatalf("xlsx: %v", err)
+	}
+	files := readZip(t, buf.Bytes())
+	sheet := files["xl/worksheets/sheet1.xml"] + files["xl/sharedStrings.xml"]
+	if strings.Contains(sheet, "extra") || strings.Count(sheet, `\N`) == 0 {
+		t.Fatal("xlsx does not hold the null text in place of the missing values")
+	}
+
+	_, err = export.New(export.WithMissing(types.MissingOptions{Ragged: types.RaggedError})).
+		ExportRows(io.Discard, testHeaders, rows)
+	if want := "row 2: expected 3 values, got 1"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("ragged error = %v, want an error containing %q", err, want)
+	}
+}
+
+func TestExportNumbers(t *testing.T) {
+	rows := []types.Row{{json.Number("1"), json.Number("1.50"), 2.0 / 3}, {json.Number("2"), json.Number("1e6"), 1e21}}
+	precision := 2
+	numbers := export.WithNumbers(types.NumberOptions{FloatPrecision: &precision})
+	want := "ID,Name,Score\n1,1.50,0.67\n2,1000000.00,1000000000000000000000.00\n"
+	tests := []struct {
+		name string
+		opts []export.Option
+	}{
+		{"sync", []export.Option{export.WithMode(types.ModeSync)}},
+		{"parallel", []export.Option{export.WithMode(types.ModeParallel), export.WithChunkSize(1)}},
+		{"global pool", []export.Option{export.WithMode(types.ModeGlobalPool), export.WithChunkSize(1)}},
+		{"split zip", []export.Option{export.WithChunkSize(10), export.WithSplitZip(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := export.New(append(tt.opts, numbers)...).ExportRows(&buf, testHeaders, rows); err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			got := buf.String()
+			if tt.name == "split zip" {
+				got = readZip(t, buf.Bytes())["part_1.csv"]
+			}
+			if got != want {
+				t.Fatalf("csv = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestExportRejectsInvalidOptions(t *testing.T) {
+	precision := 21
+	tests := []struct {
+		name string
+		opt  export.Option
+		want string
+	}{
+		{"mode", export.WithMode("bogus"), "unsupported mode"},
+		{"format", export.WithFormat("pdf"), "unsupported format"},
+		{"workers", export.WithWorkers(0), "workers must be positive"},
+		{"negative workers", export.WithWorkers(-2), "workers must be positive"},
+		{"chunk size", export.WithChunkSize(0), "chunk size must be positive"},
+		{"csv", export.WithCSVOptions(types.CSVOptions{Quoting: "some"}), "invalid CSV quoting"},
+		{"charset", export.WithCSVOptions(types.CSVOptions{Charset: "latin-9"}), "unsupported charset"},
+		{"charset with bom", export.WithCSVOptions(types.CSVOptions{Charset: "shift-jis", BOM: true}), "byte order mark"},
+		{"injection", export.WithInjection(types.InjectionOptions{Policy: "escape"}), "invalid injection policy"},
+		{"injection column", export.WithInjection(types.InjectionOptions{Columns: map[string]types.InjectionPolicy{"Name": "quote"}}), `column "Name"`},
+		{"ragged", export.WithMissing(types.MissingOptions{Ragged: "drop"}), "invalid ragged row policy"},
+		{"float precision", export.WithNumbers(types.NumberOptions{FloatPrecision: &precision}), "invalid float precision"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			src := types.NewSliceSource(testRows(3))
+			_, err := export.New(tt.opt).Export(&buf, testHeaders, src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Export = %v, want an error containing %q", err, tt.want)
+			}
+			if buf.Len() != 0 {
+				t.Fatalf("%d bytes written for invalid options", buf.Len())
+			}
+			if !src.Next() {
+				t.Fatal("rows were pulled from the source for invalid options")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package export
+
+import (
+	"github.com/turbo-export-engine/pkg/types"
+)
+
+// Default option values, matching the CLI
+const (
+	DefaultWorkers   = 4
+	DefaultChunkSize = 10000
+)
+
+type options struct {
+	mode           types.ExportMode
+	format         types.ExportFormat
+	workers        int
+	chunkSize      int
+	splitZip       bool
+	includeHeaders bool
+	xlsx           types.XLSXOptions
+	csv            types.CSVOptions
+	injection      types.InjectionOptions
+	missing        types.MissingOptions
+	numbers        types.NumberOptions
+}
+
+func defaultOptions() options {
+	return options{
+		mode:           types.ModeSync,
+		format:         types.FormatCSV,
+		workers:        DefaultWorkers,
+		chunkSize:      DefaultChunkSize,
+		includeHeaders: true,
+		xlsx: types.XLSXOptions{
+			DetectDates:   true,
+			RepeatHeaders: true,
+			BufferSheets:  true,
+		},
+	}
+}
+
+// Option configures an Exporter
+type Option func(*options)
+
+// WithMode sets the execution mode. ModeGlobalPool shares one worker pool
+// across every Exporter in the process; its size is fixed by the first
+// export that uses it.
+func WithMode(mode types.ExportMode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// WithFormat sets the output format, or the part format when splitting
+func WithFormat(format types.ExportFormat) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithWorkers sets the number of workers used by the parallel modes
+func WithWorkers(workers int) Option {
+	return func(o *options) {
+		o.workers = workers
+	}
+}
+
+// WithChunkSize sets the number of rows processed per chunk, which is also
+// the number of rows per part when splitting
+func WithChunkSize(chunkSize int) Option {
+	return func(o *options) {
+		o.chunkSize = chunkSize
+	}
+}
+
+// WithSplitZip splits the rows into parts of the chunk size and writes them
+// as a ZIP archive. includeHeaders repeats the header row in every part.
+func WithSplitZip(includeHeaders bool) Option {
+	return func(o *options) {
+		o.splitZip = true
+		o.includeHeaders = includeHeaders
+	}
+}
+
+// WithXLSXOptions sets the options shaping XLSX output, replacing the
+// defaults (date detection, header rows on rollover sheets and sheet
+// buffering enabled)
+func WithXLSXOptions(xlsx types.XLSXOptions) Option {
+	return func(o *options) {
+		o.xlsx = xlsx
+	}
+}
+
+// WithCSVOptions sets the dialect of CSV output and CSV parts
+func WithCSVOptions(csv types.CSVOptions) Option {
+	return func(o *options) {
+		o.csv = csv
+	}
+}
+
+// WithInjection protects the text cells of CSV and XLSX output and of split
+// parts against CSV (formula) injection
+func WithInjection(injection types.InjectionOptions) Option {
+	return func(o *options) {
+		o.injection = injection
+	}
+}
+
+// WithMissing sets how null values and rows whose length differs from the
+// headers are written
+func WithMissing(missing types.MissingOptions) Option {
+	return func(o *options) {
+		o.missing = missing
+	}
+}
+
+// WithNumbers sets how numbers are written as text
+func WithNumbers(numbers types.NumberOptions) Option {
+	return func(o *options) {
+		o.numbers = numbers
+	}
+}
@@ -0,0 +1,67 @@
+package types
+
+// CSVQuoting decides which CSV fields are quoted
+type CSVQuoting string
+
+const (
+	// QuoteMinimal quotes only fields containing the delimiter, a quote, a
+	// line break or leading white space
+	QuoteMinimal CSVQuoting = "minimal"
+	// QuoteAll quotes every field
+	QuoteAll CSVQuoting = "all"
+)
+
+// CSVEscape decides how quotes inside quoted CSV fields are escaped
+type CSVEscape string
+
+const (
+	// EscapeDouble doubles quotes, as in RFC 4180
+	EscapeDouble CSVEscape = "double"
+	// EscapeBackslash writes \" and \\, as expected by MySQL and some
+	// Unix tools
+	EscapeBackslash CSVEscape = "backslash"
+)
+
+// Charsets CSV output can be transcoded to
+const (
+	CharsetUTF8        = "utf-8"
+	CharsetWindows1252 = "windows-1252"
+	CharsetShiftJIS    = "shift-jis"
+)
+
+// UnencodablePolicy decides what happens to characters the CSV charset
+// cannot represent
+type UnencodablePolicy string
+
+const (
+	// UnencodableError fails the export
+	UnencodableError UnencodablePolicy = "error"
+	// UnencodableReplace writes '?' instead
+	UnencodableReplace UnencodablePolicy = "replace"
+	// UnencodableTransliterate writes the closest representable text, such
+	// as "o" for "ő" or "EUR" for "€", and '?' when there is none
+	UnencodableTransliterate UnencodablePolicy = "transliterate"
+)
+
+// CSVOptions describe the dialect of CSV output and split CSV parts. The
+// zero value is RFC 4180 with LF line endings: comma delimited, minimal
+// quoting, doubled quotes and no byte order mark.
+type CSVOptions struct {
+	// Delimiter separates fields; zero uses a comma. Use '\t' for TSV.
+	Delimiter rune `json:"delimiter,omitempty"`
+	// Quoting is the quoting style, QuoteMinimal when empty
+	Quoting CSVQuoting `json:"quoting,omitempty"`
+	// Escape is the quote escaping style, EscapeDouble when empty
+	Escape CSVEscape `json:"escape,omitempty"`
+	// CRLF ends records with \r\n instead of \n
+	CRLF bool `json:"crlf"`
+	// BOM starts the output with a UTF-8 byte order mark, which Excel
+	// needs to read UTF-8
+	BOM bool `json:"bom"`
+	// Charset is the character set text is transcoded to, UTF-8 when
+	// empty. BOM requires UTF-8.
+	Charset string `json:"charset,omitempty"`
+	// Unencodable is the policy for characters Charset cannot represent,
+	// UnencodableError when empty
+	Unencodable UnencodablePolicy `json:"unencodable,omitempty"`
+}
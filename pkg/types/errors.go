@@ -0,0 +1,30 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PanicError reports a panic recovered while processing a job or a chunk of
+// rows. The panic is contained to the failing job; the worker that ran it
+// stays available.
+type PanicError struct {
+	JobID string      // job being processed, empty outside the worker pool
+	Chunk int         // chunk index, or -1 when the panic was not in a chunk
+	Value interface{} // value passed to panic
+	Stack []byte      // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	var where []string
+	if e.JobID != "" {
+		where = append(where, "job "+e.JobID)
+	}
+	if e.Chunk >= 0 {
+		where = append(where, fmt.Sprintf("chunk %d", e.Chunk))
+	}
+	if len(where) == 0 {
+		return fmt.Sprintf("panic: %v", e.Value)
+	}
+	return fmt.Sprintf("panic in %s: %v", strings.Join(where, ", "), e.Value)
+}
@@ -0,0 +1,30 @@
+package types
+
+// InjectionPolicy decides what happens to text cells that spreadsheet
+// applications could evaluate as formulas: those starting with '=', '+',
+// '-', '@', a tab or a carriage return
+type InjectionPolicy string
+
+const (
+	// InjectionOff writes the cells verbatim
+	InjectionOff InjectionPolicy = "off"
+	// InjectionPrefix prefixes CSV cells with a single quote, which makes
+	// spreadsheet applications show them as text. XLSX cells keep their
+	// text and get a quote-prefixed style to the same effect.
+	InjectionPrefix InjectionPolicy = "prefix"
+	// InjectionStrip removes the leading characters that start a formula
+	InjectionStrip InjectionPolicy = "strip"
+	// InjectionReject fails the export
+	InjectionReject InjectionPolicy = "reject"
+)
+
+// InjectionOptions protect CSV, XLSX and split outputs against CSV
+// (formula) injection. Numeric text, such as "-12.5", is never changed.
+type InjectionOptions struct {
+	// Policy applies to every column; empty is InjectionOff
+	Policy InjectionPolicy `json:"policy,omitempty"`
+	// Columns overrides Policy by header name, e.g. to protect only the
+	// columns holding customer-supplied text, or to exempt one with
+	// InjectionOff
+	Columns map[string]InjectionPolicy `json:"columns,omitempty"`
+}
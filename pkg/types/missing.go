@@ -0,0 +1,29 @@
+package types
+
+// RaggedPolicy decides what happens to rows whose number of values differs
+// from the number of headers
+type RaggedPolicy string
+
+const (
+	// RaggedPad fills short rows with missing values. Values beyond the
+	// headers are kept.
+	RaggedPad RaggedPolicy = "pad"
+	// RaggedTruncate fills short rows with missing values and drops the
+	// values beyond the headers
+	RaggedTruncate RaggedPolicy = "truncate"
+	// RaggedError fails the export, naming the row
+	RaggedError RaggedPolicy = "error"
+)
+
+// MissingOptions decide how null and missing values are written by every
+// writer. Values are missing when an object row lacks a key or a short row
+// is padded.
+type MissingOptions struct {
+	// Null is the text written for null and missing values, such as
+	// "NULL" or "\N". Empty writes empty CSV fields and empty XLSX cells.
+	Null string `json:"null,omitempty"`
+	// Ragged is the policy for rows whose length differs from the header
+	// row, RaggedPad when empty. Rows without headers are written as they
+	// are.
+	Ragged RaggedPolicy `json:"ragged,omitempty"`
+}
@@ -0,0 +1,14 @@
+package types
+
+// NumberOptions decide how every writer writes numbers as text. Numbers
+// never use exponent notation, integers are written exactly, and integer
+// JSON number literals are kept as they appear in the input, however many
+// digits they have.
+type NumberOptions struct {
+	// FloatPrecision writes floats and fractional JSON numbers with this
+	// many decimals, 0 rounding them to integers. Nil keeps JSON literals
+	// as they are and writes other floats with the fewest digits that read
+	// back as the same float. Numeric XLSX cells always keep full
+	// precision; use a number format to show fewer decimals.
+	FloatPrecision *int `json:"float_precision,omitempty"`
+}
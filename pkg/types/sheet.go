@@ -0,0 +1,73 @@
+package types
+
+// Sheet is one named dataset of a multi-sheet XLSX export
+type Sheet struct {
+	Name    string
+	Headers []string
+	Source  RowSource
+}
+
+// SheetResult describes one worksheet of a completed XLSX export
+type SheetResult struct {
+	Name string `json:"name"`
+	Rows int    `json:"rows"` // data rows, excluding header rows
+}
+
+// SheetSource is an iterator over the sheets of a workbook. Sheets are
+// written in order, and the Source of a sheet may only be read until Next
+// is called again, so sheets can be streamed one after another from a
+// single input. The caller that created the source is responsible for
+// closing it.
+type SheetSource interface {
+	// Next advances to the next sheet. It returns false when the sheets
+	// are exhausted or an error occurred.
+	Next() bool
+	// Sheet returns the current sheet
+	Sheet() Sheet
+	// Err returns the error that stopped iteration, if any
+	Err() error
+	// Close releases resources held by the source
+	Close() error
+}
+
+// sliceSheets iterates over sheets known in advance
+type sliceSheets struct {
+	sheets []Sheet
+	pos    int
+}
+
+// NewSheetSource returns a SheetSource over sheets. Close closes the row
+// sources of all sheets.
+func NewSheetSource(sheets []Sheet) SheetSource {
+	return &sliceSheets{sheets: sheets, pos: -1}
+}
+
+func (s *sliceSheets) Next() bool {
+	if s.pos+1 >= len(s.sheets) {
+		s.pos = len(s.sheets)
+		return false
+	}
+	s.pos++
+	return true
+}
+
+func (s *sliceSheets) Sheet() Sheet {
+	return s.sheets[s.pos]
+}
+
+func (s *sliceSheets) Err() error {
+	return nil
+}
+
+func (s *sliceSheets) Close() error {
+	var first error
+	for _, sheet := range s.sheets {
+		if sheet.Source == nil {
+			continue
+		}
+		if err := sheet.Source.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,163 @@
+package types
+
+import (
+	"io"
+)
+
+// RowSource is an iterator over the rows of an export. Writers pull rows
+// with Next until it returns false, then check Err. The caller that created
+// the source is responsible for closing it.
+type RowSource interface {
+	// Next advances to the next row. It returns false when the rows are
+	// exhausted or an error occurred.
+	Next() bool
+	// Row returns the current row
+	Row() Row
+	// Err returns the error that stopped iteration, if any
+	Err() error
+	// Close releases resources held by the source
+	Close() error
+}
+
+// MemorySource is implemented by row sources over rows held in memory,
+// letting writers size their output before writing it
+type MemorySource interface {
+	RowSource
+	// Pending returns the rows not read yet
+	Pending() []Row
+}
+
+// RowDecoder yields one row per call and io.EOF after the last row
+type RowDecoder interface {
+	Next() (Row, error)
+}
+
+// sliceSource iterates over materialized rows
+type sliceSource struct {
+	rows []Row
+	pos  int
+}
+
+// NewSliceSource returns a RowSource over an in-memory slice
+func NewSliceSource(rows []Row) RowSource {
+	return &sliceSource{rows: rows, pos: -1}
+}
+
+func (s *sliceSource) Next() bool {
+	if s.pos+1 >= len(s.rows) {
+		s.pos = len(s.rows)
+		return false
+	}
+	s.pos++
+	return true
+}
+
+func (s *sliceSource) Row() Row {
+	return s.rows[s.pos]
+}
+
+func (s *sliceSource) Pending() []Row {
+	if s.pos+1 >= len(s.rows) {
+		return nil
+	}
+	return s.rows[s.pos+1:]
+}
+
+func (s *sliceSource) Err() error {
+	return nil
+}
+
+func (s *sliceSource) Close() error {
+	return nil
+}
+
+// chanSource iterates over rows sent on a channel
+type chanSource struct {
+	rows <-chan Row
+	errc <-chan error
+	row  Row
+	err  error
+}
+
+// NewChanSource returns a RowSource reading rows from a channel until it is
+// closed. If errc is not nil, the producer's error is received from it once
+// the channel closes. Close drains both channels so the producer can finish.
+func NewChanSource(rows <-chan Row, errc <-chan error) RowSource {
+	return &chanSource{rows: rows, errc: errc}
+}
+
+func (s *chanSource) Next() bool {
+	row, ok := <-s.rows
+	if !ok {
+		if s.errc != nil {
+			s.err = <-s.errc
+			s.errc = nil
+		}
+		return false
+	}
+	s.row = row
+	return true
+}
+
+func (s *chanSource) Row() Row {
+	return s.row
+}
+
+func (s *chanSource) Err() error {
+	return s.err
+}
+
+func (s *chanSource) Close() error {
+	for range s.rows {
+	}
+	if s.errc != nil {
+		<-s.errc
+		s.errc = nil
+	}
+	return nil
+}
+
+// decoderSource adapts a RowDecoder to RowSource
+type decoderSource struct {
+	dec  RowDecoder
+	row  Row
+	err  error
+	done bool
+}
+
+// NewDecoderSource returns a RowSource pulling rows from dec. Close closes
+// dec if it implements io.Closer.
+func NewDecoderSource(dec RowDecoder) RowSource {
+	return &decoderSource{dec: dec}
+}
+
+func (s *decoderSource) Next() bool {
+	if s.done {
+		return false
+	}
+	row, err := s.dec.Next()
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		s.done = true
+		return false
+	}
+	s.row = row
+	return true
+}
+
+func (s *decoderSource) Row() Row {
+	return s.row
+}
+
+func (s *decoderSource) Err() error {
+	return s.err
+}
+
+func (s *decoderSource) Close() error {
+	if closer, ok := s.dec.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
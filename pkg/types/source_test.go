@@ -0,0 +1,132 @@
+package types
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// drain pulls every row of src
+func drain(src RowSource) []Row {
+	var rows []Row
+	for src.Next() {
+		rows = append(rows, src.Row())
+	}
+	return rows
+}
+
+func TestSliceSource(t *testing.T) {
+	want := []Row{{1}, {2}, {3}}
+	src := NewSliceSource(want)
+	if got := drain(src); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows = %v, want %v", got, want)
+	}
+	if src.Next() {
+		t.Fatal("Next after the last row = true")
+	}
+	if err := src.Err(); err != nil {
+		t.Fatalf("Err = %v", err)
+	}
+}
+
+func TestChanSourceReportsProducerError(t *testing.T) {
+	errRead := errors.New("read failed")
+	rows := make(chan Row)
+	errc := make(chan error)
+	go func() {
+		rows <- Row{1}
+		close(rows)
+		errc <- errRead
+	}()
+
+	src := NewChanSource(rows, errc)
+	if got := drain(src); len(got) != 1 {
+		t.Fatalf("got %d rows, want 1", len(got))
+	}
+	if err := src.Err(); !errors.Is(err, errRead) {
+		t.Fatalf("Err = %v, want %v", err, errRead)
+	}
+}
+
+func TestChanSourceCloseReleasesProducer(t *testing.T) {
+	rows := make(chan Row)
+	errc := make(chan error)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for i := 0; i < 10; i++ {
+			rows <- Row{i}
+		}
+		close(rows)
+		// Unbuffered, so this blocks until the source receives it
+		errc <- errors.New("late error")
+	}()
+
+	src := NewChanSource(rows, errc)
+	if !src.Next() {
+		t.Fatal("Next = false, want the first row")
+	}
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close = %v", err)
+	}
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("producer still blocked after Close")
+	}
+}
+
+// rowDecoder yields rows, then err or io.EOF
+type rowDecoder struct {
+	rows   []Row
+	err    error
+	closed bool
+}
+
+func (d *rowDecoder) Next() (Row, error) {
+	if len(d.rows) == 0 {
+		if d.err != nil {
+			return nil, d.err
+		}
+		return nil, io.EOF
+	}
+	row := d.rows[0]
+	d.rows = d.rows[1:]
+	return row, nil
+}
+
+func (d *rowDecoder) Close() error {
+	d.closed = true
+	return nil
+}
+
+func TestDecoderSource(t *testing.T) {
+	errBad := errors.New("bad row")
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"end of rows", nil},
+		{"decode error", errBad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := &rowDecoder{rows: []Row{{1}, {2}}, err: tt.err}
+			src := NewDecoderSource(dec)
+			if got := drain(src); len(got) != 2 {
+				t.Fatalf("got %d rows, want 2", len(got))
+			}
+			if src.Next() {
+				t.Fatal("Next after the end = true")
+			}
+			if err := src.Err(); !errors.Is(err, tt.err) {
+				t.Fatalf("Err = %v, want %v", err, tt.err)
+			}
+			if err := src.Close(); err != nil || !dec.closed {
+				t.Fatalf("Close = %v, decoder closed %v", err, dec.closed)
+			}
+		})
+	}
+}
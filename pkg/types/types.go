@@ -1,5 +1,10 @@
 package types
 
+import (
+	"context"
+	"io"
+)
+
 type ExportMode string
 
 const (
@@ -24,6 +29,18 @@ type ExportConfig struct {
 	ChunkSize  int          `json:"chunk_size"`
 	InputPath  string       `json:"input_path"`
 	OutputPath string       `json:"output_path"`
+	XLSX       XLSXOptions  `json:"xlsx"`
+	CSV        CSVOptions   `json:"csv"`
+	// Injection protects text cells against CSV (formula) injection
+	Injection InjectionOptions `json:"injection"`
+	// Missing decides how null values and ragged rows are written
+	Missing MissingOptions `json:"missing"`
+	// Numbers decides how numbers are written as text
+	Numbers NumberOptions `json:"numbers"`
+
+	// Output, when set, receives the exported bytes instead of the file at
+	// OutputPath. It does not need to be seekable.
+	Output io.Writer `json:"-"`
 }
 
 type ExportJob struct {
@@ -32,6 +49,26 @@ type ExportJob struct {
 	Rows    []Row
 	Headers []string
 	Result  chan error
+
+	// Source, when set, supplies rows incrementally and takes precedence
+	// over Rows
+	Source RowSource
+
+	// Sheets, when set, makes the job an XLSX workbook with one worksheet
+	// per sheet; Headers, Rows and Source are then ignored
+	Sheets SheetSource
+
+	// SheetResults lists the worksheets of a completed XLSX job, including
+	// the sheets rows rolled over into
+	SheetResults []SheetResult
+
+	// LossyCells counts the cells of a completed CSV job that were
+	// replaced or transliterated to fit the output charset
+	LossyCells int
+
+	// Context carries the job's cancellation to the worker that processes
+	// it. A nil Context never cancels.
+	Context context.Context
 }
 
 type SplitZipConfig struct {
@@ -43,13 +80,27 @@ type SplitZipConfig struct {
 	Workers        int          `json:"workers"`
 	IncludeHeaders bool         `json:"include_headers"`
 	OutputPath     string       `json:"output_path"`
+	XLSX           XLSXOptions  `json:"xlsx"`
+	CSV            CSVOptions   `json:"csv"`
+	// Injection protects the text cells of every part against CSV
+	// (formula) injection
+	Injection InjectionOptions `json:"injection"`
+	// Missing decides how null values and ragged rows are written
+	Missing MissingOptions `json:"missing"`
+	// Numbers decides how numbers are written as text
+	Numbers NumberOptions `json:"numbers"`
+
+	// Output, when set, receives the ZIP archive instead of the file at
+	// OutputPath. It does not need to be seekable.
+	Output io.Writer `json:"-"`
 }
 
 type PartResult struct {
-	PartIndex int
-	Data      []byte
-	RowCount  int
-	Error     error
+	PartIndex  int
+	Data       []byte
+	RowCount   int
+	LossyCells int
+	Error      error
 }
 
 type SplitZipResult struct {
@@ -57,4 +108,5 @@ type SplitZipResult struct {
 	TotalParts int      `json:"total_parts"`
 	TotalRows  int      `json:"total_rows"`
 	PartFiles  []string `json:"part_files"`
+	LossyCells int      `json:"lossy_cells"`
 }
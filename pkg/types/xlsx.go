@@ -0,0 +1,121 @@
+package types
+
+import "time"
+
+// CellType declares how the values of an XLSX column are written
+type CellType string
+
+const (
+	CellString   CellType = "string"
+	CellNumber   CellType = "number"
+	CellBool     CellType = "bool"
+	CellDate     CellType = "date"
+	CellDateTime CellType = "datetime"
+	// CellHyperlink makes a URL, or an object with "url" and "text" keys,
+	// a clickable link. URLs starting with # link to a location in the
+	// workbook, e.g. "#Orders!A1".
+	CellHyperlink CellType = "hyperlink"
+	// CellFormula writes a string as a formula, with or without a leading
+	// '='. "{row}" is replaced by the number of the cell's row.
+	CellFormula CellType = "formula"
+)
+
+// ControlCharPolicy decides what happens to characters XML 1.0 cannot
+// represent, such as \x00 to \x08 and \x0B, in XLSX text
+type ControlCharPolicy string
+
+const (
+	// ControlCharsStrip removes the characters
+	ControlCharsStrip ControlCharPolicy = "strip"
+	// ControlCharsReplace replaces them with U+FFFD
+	ControlCharsReplace ControlCharPolicy = "replace"
+	// ControlCharsEncode keeps them as Excel's _xHHHH_ escapes, which
+	// Excel decodes back into the original characters
+	ControlCharsEncode ControlCharPolicy = "encode"
+)
+
+// LongTextPolicy decides what happens to text longer than the 32,767
+// characters an Excel cell holds
+type LongTextPolicy string
+
+const (
+	LongTextTruncate LongTextPolicy = "truncate"
+	LongTextError    LongTextPolicy = "error"
+)
+
+// DocumentProperties are the workbook metadata written to docProps/core.xml
+// and docProps/app.xml. Empty fields are omitted.
+type DocumentProperties struct {
+	Title    string `json:"title,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Creator  string `json:"creator,omitempty"`
+	Company  string `json:"company,omitempty"`
+	Keywords string `json:"keywords,omitempty"`
+	// Created is the creation timestamp, also written as the last
+	// modification; zero omits both
+	Created time.Time `json:"created,omitempty"`
+}
+
+// XLSXOptions tune the XLSX output of the builder and of split XLSX parts
+type XLSXOptions struct {
+	// ColumnTypes declares cell types by header name. Undeclared columns
+	// are typed from their JSON values: numbers and booleans are written
+	// as such and everything else as text.
+	ColumnTypes map[string]CellType `json:"column_types,omitempty"`
+	// DetectDates converts undeclared RFC 3339 and ISO 8601 date strings
+	// into Excel dates
+	DetectDates bool `json:"detect_dates"`
+	// SharedStrings writes text cells through a deduplicated
+	// xl/sharedStrings.xml table instead of inline strings
+	SharedStrings bool `json:"shared_strings"`
+	// SharedStringsLimit caps the memory of the shared strings table in
+	// bytes; strings that do not fit are written inline. Zero uses a 64 MiB
+	// default.
+	SharedStringsLimit int64 `json:"shared_strings_limit,omitempty"`
+	// BoldHeaders writes the header row in bold
+	BoldHeaders bool `json:"bold_headers"`
+	// HeaderFill fills the header row with an RGB hex color such as
+	// "DDEBF7"; empty leaves it unfilled
+	HeaderFill string `json:"header_fill,omitempty"`
+	// NumberFormats sets the number format of a column's numeric and date
+	// cells by header name: currency, percent, date, datetime, thousands,
+	// decimal or an Excel format code such as "0.000"
+	NumberFormats map[string]string `json:"number_formats,omitempty"`
+	// ColumnWidths sets column widths in characters by header name
+	ColumnWidths map[string]float64 `json:"column_widths,omitempty"`
+	// AutoWidth estimates the width of the remaining columns from the
+	// header and the content of the first rows
+	AutoWidth bool `json:"auto_width"`
+	// MaxRowsPerSheet rolls rows over into a new sheet once a sheet holds
+	// this many rows, header included. Zero or values above Excel's limit
+	// of 1,048,576 rows use that limit.
+	MaxRowsPerSheet int `json:"max_rows_per_sheet,omitempty"`
+	// RepeatHeaders writes the header row at the top of every sheet rows
+	// roll over into
+	RepeatHeaders bool `json:"repeat_headers"`
+	// FreezeHeader freezes the header row so it stays in view
+	FreezeHeader bool `json:"freeze_header"`
+	// AutoFilter adds filter buttons to the header row over the data range.
+	// It is implied by Table.
+	AutoFilter bool `json:"auto_filter"`
+	// Table formats the header row and rows of each sheet as an Excel table
+	Table bool `json:"table"`
+	// TableName names the first table; further tables get a numeric suffix.
+	// Empty names tables Table1, Table2, ...
+	TableName string `json:"table_name,omitempty"`
+	// TableStyle is the built-in table style, TableStyleMedium2 when empty
+	TableStyle string `json:"table_style,omitempty"`
+	// ControlChars is the policy for characters XML 1.0 cannot represent;
+	// empty strips them. Invalid UTF-8 is replaced with U+FFFD, or
+	// stripped along with them.
+	ControlChars ControlCharPolicy `json:"control_chars,omitempty"`
+	// LongText is the policy for text over Excel's cell limit; empty
+	// truncates it
+	LongText LongTextPolicy `json:"long_text,omitempty"`
+	// Properties sets the document properties of the workbook
+	Properties DocumentProperties `json:"properties"`
+	// BufferSheets spools the rows of streamed sheets to a temporary file
+	// so the sheet dimension can be written ahead of them. Sheets over rows
+	// held in memory always get a dimension.
+	BufferSheets bool `json:"buffer_sheets"`
+}